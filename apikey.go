@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// apiKeyModel is the fastlane api_key JSON format.
+type apiKeyModel struct {
+	KeyID    string `json:"key_id"`
+	IssuerID string `json:"issuer_id"`
+	Key      string `json:"key"`
+	Duration int    `json:"duration,omitempty"`
+	InHouse  bool   `json:"in_house,omitempty"`
+}
+
+// maxAPIKeyDuration is the longest token lifetime App Store Connect accepts, in seconds.
+const maxAPIKeyDuration = 1200
+
+// apiKeyEnvPrefixes are the env vars fastlane reads an App Store Connect API key from.
+var apiKeyEnvPrefixes = []string{"APP_STORE_CONNECT_API_KEY", "SPACESHIP_CONNECT_API_"}
+
+// apiKeyEnvKeys returns the sorted names of the non-empty API key env vars of the workflow,
+// fastlane uses them if no api_key_path is passed.
+func apiKeyEnvKeys() []string {
+	keys := []string{}
+	for _, env := range os.Environ() {
+		split := strings.SplitN(env, "=", 2)
+		if len(split) != 2 || split[1] == "" {
+			continue
+		}
+		for _, prefix := range apiKeyEnvPrefixes {
+			if strings.HasPrefix(split[0], prefix) {
+				keys = append(keys, split[0])
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isRemoteURL(pth string) bool {
+	return strings.HasPrefix(pth, "http://") || strings.HasPrefix(pth, "https://")
+}
+
+func downloadContent(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body, error: %s", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status code: %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func validateAPIKeyFile(pth string) error {
+	content, err := fileutil.ReadBytesFromFile(pth)
+	if err != nil {
+		return err
+	}
+
+	var apiKey map[string]interface{}
+	if err := json.Unmarshal(content, &apiKey); err != nil {
+		return fmt.Errorf("not a valid JSON file, error: %s", err)
+	}
+
+	return nil
+}
+
+// prepareAPIKeyFile returns the absolute path of the validated local key file.
+func prepareAPIKeyFile(pth string) (string, error) {
+	if err := validateAPIKeyFile(pth); err != nil {
+		return "", err
+	}
+
+	return pathutil.AbsPath(pth)
+}
+
+// downloadAPIKey downloads the API key and writes it to a temporary file,
+// readable only by the current user.
+func downloadAPIKey(url string) (string, error) {
+	log.Printf("Downloading App Store Connect API key...")
+
+	content, err := downloadContent(url)
+	if err != nil {
+		return "", err
+	}
+
+	pth, err := writeSecretFile("api_key", "api_key.json", content)
+	if err != nil {
+		return "", err
+	}
+
+	if err := validateAPIKeyFile(pth); err != nil {
+		if rmErr := secureRemoveFile(pth); rmErr != nil {
+			log.Warnf("Failed to remove App Store Connect API key, error: %s", rmErr)
+		}
+		return "", err
+	}
+
+	return pth, nil
+}
+
+// writeAPIKeyContent decodes the base64 encoded API key and writes it
+// to a temporary file, readable only by the current user.
+func writeAPIKeyContent(contentBase64 string) (string, error) {
+	content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(contentBase64))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode base64 content, error: %s", err)
+	}
+
+	pth, err := writeSecretFile("api_key", "api_key.json", content)
+	if err != nil {
+		return "", err
+	}
+
+	if err := validateAPIKeyFile(pth); err != nil {
+		if rmErr := secureRemoveFile(pth); rmErr != nil {
+			log.Warnf("Failed to remove App Store Connect API key, error: %s", rmErr)
+		}
+		return "", err
+	}
+
+	return pth, nil
+}
+
+// writeAPIKeyFromParts assembles the fastlane api_key JSON and writes it
+// to a temporary file, readable only by the current user.
+func writeAPIKeyFromParts(keyID, issuerID, key string, duration int, inHouse bool) (string, error) {
+	content, err := json.Marshal(apiKeyModel{
+		KeyID:    keyID,
+		IssuerID: issuerID,
+		Key:      strings.TrimSpace(key),
+		Duration: duration,
+		InHouse:  inHouse,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return writeSecretFile("api_key", "api_key.json", content)
+}
+
+// writeAPIKeyWithOptions copies the api_key JSON file to a temporary file, readable only by the current user,
+// setting the token duration (if not zero) and the in_house flag of enterprise accounts (if true).
+func writeAPIKeyWithOptions(pth string, duration int, inHouse bool) (string, error) {
+	content, err := fileutil.ReadBytesFromFile(pth)
+	if err != nil {
+		return "", err
+	}
+
+	var apiKey map[string]interface{}
+	if err := json.Unmarshal(content, &apiKey); err != nil {
+		return "", fmt.Errorf("not a valid JSON file, error: %s", err)
+	}
+
+	if duration > 0 {
+		apiKey["duration"] = duration
+	}
+	if inHouse {
+		apiKey["in_house"] = true
+	}
+
+	content, err = json.Marshal(apiKey)
+	if err != nil {
+		return "", err
+	}
+	return writeSecretFile("api_key", "api_key.json", content)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+)
+
+// CertificateModel ...
+type CertificateModel struct {
+	Path           string
+	CommonName     string
+	SHA1           string
+	Serial         string
+	TeamID         string
+	ExpirationDate time.Time
+}
+
+func newCertificateModel(pth string, cert *x509.Certificate) CertificateModel {
+	// Apple stores the team ID of the signing certificates in the organizational unit
+	teamID := ""
+	if len(cert.Subject.OrganizationalUnit) > 0 {
+		teamID = cert.Subject.OrganizationalUnit[0]
+	}
+
+	return CertificateModel{
+		Path:           pth,
+		CommonName:     cert.Subject.CommonName,
+		SHA1:           strings.ToUpper(fmt.Sprintf("%x", sha1.Sum(cert.Raw))),
+		Serial:         strings.ToUpper(cert.SerialNumber.Text(16)),
+		TeamID:         teamID,
+		ExpirationDate: cert.NotAfter,
+	}
+}
+
+// parseCertificate parses a DER or PEM encoded certificate file.
+func parseCertificate(pth string) (CertificateModel, error) {
+	content, err := fileutil.ReadBytesFromFile(pth)
+	if err != nil {
+		return CertificateModel{}, err
+	}
+
+	if block, _ := pem.Decode(content); block != nil {
+		content = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(content)
+	if err != nil {
+		return CertificateModel{}, err
+	}
+
+	return newCertificateModel(pth, cert), nil
+}
+
+// findCertificates parses every certificate in the directory.
+func findCertificates(dir string) ([]CertificateModel, error) {
+	pths := []string{}
+	if err := filepath.Walk(dir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.ToLower(filepath.Ext(pth)) == ".cer" {
+			pths = append(pths, pth)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(pths)
+
+	certificates := []CertificateModel{}
+	for _, pth := range pths {
+		certificate, err := parseCertificate(pth)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
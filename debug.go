@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-tools/go-steputils/input"
+)
+
+// debugMode enables logging the details of every fastlane run.
+var debugMode bool
+
+// dryRun logs the fastlane commands instead of running them.
+var dryRun bool
+
+// debugEnvPrefixes select the environment variables relevant to a fastlane match run.
+var debugEnvPrefixes = []string{"FASTLANE_", "MATCH_", "SPACESHIP_", "BUNDLE_", "GEM_", "RUBY", "SSH_", "GIT_", "LANG", "LC_"}
+
+// sensitiveEnvKeyParts mark the environment variables whose values are never printed.
+var sensitiveEnvKeyParts = []string{"PASSWORD", "TOKEN", "SECRET", "SESSION", "AUTHORIZATION", "PRIVATE_KEY", "ACCESS_KEY", "API_KEY", "CONNECT_API"}
+
+func isSensitiveEnvKey(key string) bool {
+	for _, part := range sensitiveEnvKeyParts {
+		if strings.Contains(strings.ToUpper(key), part) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskedEnv returns the KEY=value pair with the value masked if the key is sensitive
+// or the value is one of the secrets.
+func maskedEnv(env string, secrets []string) string {
+	split := strings.SplitN(env, "=", 2)
+	if len(split) != 2 {
+		return env
+	}
+
+	key, value := split[0], split[1]
+	if isSensitiveEnvKey(key) {
+		return key + "=" + input.SecureInput(value)
+	}
+
+	for _, secret := range secrets {
+		if secret != "" && value == secret {
+			return key + "=" + input.SecureInput(value)
+		}
+	}
+
+	return env
+}
+
+func toolVersion(name string, args ...string) string {
+	out, err := command.New(name, args...).RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Sprintf("not available (%s)", err)
+	}
+	return out
+}
+
+// logDebugInfo prints the resolved tool versions and the relevant environment,
+// both the inherited one and the one set for fastlane, with the secrets masked.
+func logDebugInfo(fastlaneVersion string, envs, secrets []string) {
+	fmt.Println()
+	log.Infof("Debug information")
+
+	log.Printf("ruby: %s", toolVersion("ruby", "--version"))
+	log.Printf("bundler: %s", toolVersion("bundle", "--version"))
+	log.Printf("fastlane: %s", fastlaneVersion)
+
+	fmt.Println()
+	log.Printf("Environment:")
+
+	inherited := []string{}
+	for _, env := range os.Environ() {
+		for _, prefix := range debugEnvPrefixes {
+			if strings.HasPrefix(env, prefix) {
+				inherited = append(inherited, env)
+				break
+			}
+		}
+	}
+	sort.Strings(inherited)
+
+	for _, env := range inherited {
+		log.Printf("  %s", maskedEnv(env, secrets))
+	}
+
+	fmt.Println()
+	log.Printf("Set for fastlane:")
+	for _, env := range envs {
+		log.Printf("  %s", maskedEnv(env, secrets))
+	}
+}
+
+// logDebugCommand prints where and how a fastlane command is run.
+func logDebugCommand(cmdSlice []string, workDir string, envs, secrets []string) {
+	if workDir == "" {
+		workDir, _ = os.Getwd()
+	}
+
+	log.Printf("Working directory: %s", workDir)
+	for _, env := range envs {
+		log.Printf("  %s", maskedEnv(env, secrets))
+	}
+	log.Printf("  %s", maskedCommandArgs(cmdSlice, secrets...))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-tools/go-steputils/input"
+)
+
+// validateDecrypt checks the inputs required to export the decrypted storage content.
+func (configs ConfigsModel) validateDecrypt() error {
+	if configs.StorageMode != "git" && configs.StorageMode != "s3" {
+		return fmt.Errorf("Decrypt, not available for storage mode: %s", configs.StorageMode)
+	}
+
+	if err := input.ValidateIfNotEmpty(configs.OutputPath); err != nil {
+		return fmt.Errorf("Output path %s", err)
+	}
+
+	return nil
+}
+
+// decryptedRepoPathFromOutput returns the working directory
+// match prints after decrypting the storage.
+func decryptedRepoPathFromOutput(out string) string {
+	exp := regexp.MustCompile(`Repo is at: '(.+)'`)
+	match := exp.FindStringSubmatch(out)
+	if len(match) == 2 {
+		return match[1]
+	}
+	return ""
+}
+
+// exportDecryptedAssets copies the decrypted certificates and profiles to the output directory.
+func exportDecryptedAssets(out, outputDir string) error {
+	repoDir := decryptedRepoPathFromOutput(out)
+	if repoDir == "" {
+		return errors.New("failed to find the decrypted repository path in the match output")
+	}
+
+	for _, dir := range []string{"certs", "profiles"} {
+		src := filepath.Join(repoDir, dir)
+		if exist, err := pathutil.IsDirExists(src); err != nil {
+			return err
+		} else if !exist {
+			continue
+		}
+
+		dst := filepath.Join(outputDir, dir)
+		if err := pathutil.EnsureDirExist(dst); err != nil {
+			return err
+		}
+
+		if err := command.CopyDir(src, dst, true); err != nil {
+			return err
+		}
+	}
+
+	return command.RemoveDir(repoDir)
+}
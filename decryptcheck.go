@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// checkDecryptPassword decrypts one of the certificates of the storage clone,
+// to find a wrong decrypt password before running match. Only errBadDecrypt means the password is wrong.
+func checkDecryptPassword(storage nativeStorageModel) error {
+	candidates := []string{}
+	for _, pattern := range []string{"certs/*/*.cer", "profiles/*/*.mobileprovision", "profiles/*/*.provisionprofile"} {
+		matches, err := filepath.Glob(filepath.Join(storage.Dir, pattern))
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, matches...)
+	}
+
+	if len(candidates) == 0 {
+		log.Warnf("The storage has no certificates or profiles yet, skipping the decrypt password check")
+		return nil
+	}
+
+	content, err := fileutil.ReadStringFromFile(candidates[0])
+	if err != nil {
+		return err
+	}
+
+	if _, err := decryptMatchFile(content, storage.Password); err != nil {
+		if err == errBadDecrypt {
+			return err
+		}
+		return fmt.Errorf("failed to decrypt %s, error: %s", filepath.Base(candidates[0]), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-tools/go-steputils/input"
+)
+
+// validateDevices checks the device registration inputs,
+// registered devices are only added to the adhoc and development profiles.
+func (configs ConfigsModel) validateDevices(types []string) error {
+	if configs.DevicesFile == "" && configs.Devices == "" {
+		return nil
+	}
+
+	if configs.DevicesFile != "" && configs.Devices != "" {
+		return errors.New("Devices file and Devices can not be set at the same time")
+	}
+
+	if configs.DevicesFile != "" {
+		if err := input.ValidateIfPathExists(configs.DevicesFile); err != nil {
+			return fmt.Errorf("Devices file %s", err)
+		}
+	}
+
+	if _, err := parseDevices(configs.Devices); err != nil {
+		return fmt.Errorf("Devices, %s", err)
+	}
+
+	for _, matchType := range types {
+		if supportsForceForNewDevices(matchType) {
+			return nil
+		}
+	}
+
+	return errors.New("Device registration, only available for adhoc and development types")
+}
+
+// parseDevices parses the inline device list, one `UDID,Device Name` pair per line.
+func parseDevices(devices string) ([][]string, error) {
+	parsed := [][]string{}
+	for _, line := range strings.Split(devices, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		split := strings.SplitN(line, ",", 2)
+		if len(split) != 2 || strings.TrimSpace(split[0]) == "" || strings.TrimSpace(split[1]) == "" {
+			return nil, fmt.Errorf("invalid device: %s, expected format: UDID,Device Name", line)
+		}
+
+		parsed = append(parsed, []string{strings.TrimSpace(split[0]), strings.TrimSpace(split[1])})
+	}
+	return parsed, nil
+}
+
+// prepareDevicesFile returns the path of the devices file to register,
+// the inline device list is written into a file in the fastlane register_devices format.
+func (configs ConfigsModel) prepareDevicesFile() (string, error) {
+	if configs.DevicesFile != "" {
+		return pathutil.AbsPath(configs.DevicesFile)
+	}
+
+	devices, err := parseDevices(configs.Devices)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{"Device ID\tDevice Name"}
+	for _, device := range devices {
+		lines = append(lines, strings.Join(device, "\t"))
+	}
+
+	pth, err := writeSecretFile("devices", "devices.txt", []byte(strings.Join(lines, "\n")+"\n"))
+	if err != nil {
+		return "", err
+	}
+	registerSecretFileCleanup(pth, "devices file")
+
+	return pth, nil
+}
+
+// registerDevicesArgs returns the `fastlane run register_devices` parameters.
+func registerDevicesArgs(devicesFile, apiKeyPath, username, teamID, teamName string) []string {
+	args := []string{fmt.Sprintf("devices_file:%s", devicesFile)}
+
+	if apiKeyPath != "" {
+		args = append(args, fmt.Sprintf("api_key_path:%s", apiKeyPath))
+	}
+
+	if username != "" {
+		args = append(args, fmt.Sprintf("username:%s", username))
+	}
+
+	if teamID != "" {
+		args = append(args, fmt.Sprintf("team_id:%s", teamID))
+	}
+
+	if teamName != "" {
+		args = append(args, fmt.Sprintf("team_name:%s", teamName))
+	}
+
+	return args
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-xcode/plistutil"
+)
+
+// entitlementsNotInProfiles are entitlement prefixes granted without a provisioning profile capability.
+var entitlementsNotInProfiles = []string{
+	"com.apple.security.",
+}
+
+// readEntitlements parses the entitlements file referenced by the target's
+// CODE_SIGN_ENTITLEMENTS build setting, nil if the target has none.
+func readEntitlements(target xcodeTarget, configuration string) (map[string]interface{}, error) {
+	pth := targetBuildSetting(target, configuration, "CODE_SIGN_ENTITLEMENTS")
+	if pth == "" {
+		return nil, nil
+	}
+	if strings.Contains(pth, "$") {
+		return nil, fmt.Errorf("unresolved CODE_SIGN_ENTITLEMENTS: %s", pth)
+	}
+	if !filepath.IsAbs(pth) {
+		pth = filepath.Join(target.ProjectDir, pth)
+	}
+
+	entitlements, err := plistutil.NewPlistDataFromFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the entitlements %s, error: %s", pth, err)
+	}
+	return entitlements, nil
+}
+
+// missingEntitlements returns the sorted entitlement keys the profile doesn't include.
+func missingEntitlements(entitlements map[string]interface{}, profile ProfileModel) []string {
+	missing := []string{}
+	for key := range entitlements {
+		skip := false
+		for _, prefix := range entitlementsNotInProfiles {
+			if strings.HasPrefix(key, prefix) {
+				skip = true
+			}
+		}
+		if skip {
+			continue
+		}
+
+		if _, ok := profile.Entitlements[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// checkEntitlements compares the bundle identifier and the entitlements of each signable target
+// of the project with the given profiles, returning one issue per target and profile mismatch.
+func checkEntitlements(projectPath, scheme string, profiles []ProfileModel) ([]string, error) {
+	targets, configuration, err := selectTargets(projectPath, scheme)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no app or extension target found")
+	}
+
+	issues := []string{}
+	for _, target := range targets {
+		appID := targetBuildSetting(target, configuration, "PRODUCT_BUNDLE_IDENTIFIER")
+		if appID == "" || strings.Contains(appID, "$") {
+			continue
+		}
+
+		entitlements, err := readEntitlements(target, configuration)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %s", target.Name, err)
+		}
+
+		found := false
+		for _, profile := range profiles {
+			if profile.AppID != appID {
+				continue
+			}
+			found = true
+
+			if missing := missingEntitlements(entitlements, profile); len(missing) > 0 {
+				issues = append(issues, fmt.Sprintf("%s: provisioning profile %s is missing the entitlements: %s", target.Name, profile.Name, strings.Join(missing, ", ")))
+			}
+		}
+
+		if !found {
+			issues = append(issues, fmt.Sprintf("%s: no provisioning profile fetched for %s", target.Name, appID))
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import "time"
+
+// expiringProfiles returns the profiles expiring within the given number of days.
+func expiringProfiles(profiles []ProfileModel, days int, now time.Time) []ProfileModel {
+	threshold := now.AddDate(0, 0, days)
+
+	expiring := []ProfileModel{}
+	for _, profile := range profiles {
+		if profile.ExpirationDate.Before(threshold) {
+			expiring = append(expiring, profile)
+		}
+	}
+	return expiring
+}
+
+// expiringCertificates returns the certificates expiring within the given number of days.
+func expiringCertificates(certificates []CertificateModel, days int, now time.Time) []CertificateModel {
+	threshold := now.AddDate(0, 0, days)
+
+	expiring := []CertificateModel{}
+	for _, certificate := range certificates {
+		if certificate.ExpirationDate.Before(threshold) {
+			expiring = append(expiring, certificate)
+		}
+	}
+	return expiring
+}
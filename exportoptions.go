@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+)
+
+var exportMethods = map[string]string{
+	"adhoc":       "ad-hoc",
+	"appstore":    "app-store",
+	"development": "development",
+	"enterprise":  "enterprise",
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	if err := xml.EscapeText(&buf, []byte(s)); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+// exportOptionsContent renders the exportOptions.plist of the given type,
+// mapping each app identifier to the name of its provisioning profile.
+func exportOptionsContent(matchType, teamID string, profiles []ProfileModel) string {
+	profileNames := map[string]string{}
+	appIDs := []string{}
+	for _, profile := range profiles {
+		if profile.Type != matchType {
+			continue
+		}
+		if _, ok := profileNames[profile.AppID]; !ok {
+			appIDs = append(appIDs, profile.AppID)
+		}
+		profileNames[profile.AppID] = profile.Name
+		if teamID == "" {
+			teamID = profile.TeamID
+		}
+	}
+	sort.Strings(appIDs)
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&buf, "\t<key>method</key>\n\t<string>%s</string>\n", xmlEscape(exportMethods[matchType]))
+	fmt.Fprintf(&buf, "\t<key>signingStyle</key>\n\t<string>manual</string>\n")
+	if teamID != "" {
+		fmt.Fprintf(&buf, "\t<key>teamID</key>\n\t<string>%s</string>\n", xmlEscape(teamID))
+	}
+	buf.WriteString("\t<key>provisioningProfiles</key>\n\t<dict>\n")
+	for _, appID := range appIDs {
+		fmt.Fprintf(&buf, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", xmlEscape(appID), xmlEscape(profileNames[appID]))
+	}
+	buf.WriteString("\t</dict>\n</dict>\n</plist>\n")
+
+	return buf.String()
+}
+
+// writeExportOptions writes the exportOptions.plist of every type into the directory
+// and returns their paths.
+func writeExportOptions(dir string, types []string, teamID string, profiles []ProfileModel) ([]string, error) {
+	pths := []string{}
+	for _, matchType := range types {
+		pth := filepath.Join(dir, fmt.Sprintf("exportOptions_%s.plist", matchType))
+		if err := fileutil.WriteStringToFile(pth, exportOptionsContent(matchType, teamID, profiles)); err != nil {
+			return nil, err
+		}
+		pths = append(pths, pth)
+	}
+	return pths, nil
+}
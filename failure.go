@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// failureReason is a known cause of a failed fastlane run, with the exit code the step fails with.
+type failureReason struct {
+	ExitCode int
+	Result   string
+	Message  string
+	Patterns []*regexp.Regexp
+}
+
+// The exit codes of the known failure reasons, documented in the step.yml description.
+const (
+	authExitCode           = 10
+	twoFactorExitCode      = 11
+	decryptExitCode        = 12
+	missingProfileExitCode = 13
+	gitAccessExitCode      = 14
+	timeoutExitCode        = 15
+)
+
+// failureReasons are checked in order, the first matching one is reported.
+var failureReasons = []failureReason{
+	{
+		ExitCode: twoFactorExitCode,
+		Result:   "failed_2fa",
+		Message:  "Two-factor authentication required",
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)two-(factor|step) (authentication|verification)`),
+			regexp.MustCompile(`(?i)please enter the \d digit code`),
+			regexp.MustCompile(`(?i)need to provide the 2FA code`),
+		},
+	},
+	{
+		ExitCode: authExitCode,
+		Result:   "failed_auth",
+		Message:  "Apple authentication failed",
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)invalid username and password combination`),
+			regexp.MustCompile(`(?i)authentication credentials are missing or invalid`),
+			regexp.MustCompile(`(?i)NOT_AUTHORIZED`),
+			regexp.MustCompile(`(?i)Apple ID.*(locked|disabled)`),
+		},
+	},
+	{
+		ExitCode: decryptExitCode,
+		Result:   "failed_decrypt",
+		Message:  "Wrong decrypt password (MATCH_PASSWORD)",
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)invalid password passed via 'MATCH_PASSWORD'`),
+			regexp.MustCompile(`(?i)couldn't decrypt the repo`),
+			regexp.MustCompile(`(?i)bad decrypt`),
+		},
+	},
+	{
+		ExitCode: missingProfileExitCode,
+		Result:   "failed_missing_profile",
+		Message:  "Certificate or provisioning profile missing from the storage in readonly mode",
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile("(?i)can not create a new one because you enabled `readonly`"),
+			regexp.MustCompile(`(?i)no matching provisioning profiles? found`),
+			regexp.MustCompile(`(?i)no code signing identity found`),
+		},
+	},
+	{
+		ExitCode: gitAccessExitCode,
+		Result:   "failed_git_access",
+		Message:  "Access to the git storage denied",
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)permission denied \(publickey\)`),
+			regexp.MustCompile(`(?i)repository not found`),
+			regexp.MustCompile(`(?i)authentication failed for`),
+			regexp.MustCompile(`(?i)could not read from remote repository`),
+			regexp.MustCompile(`(?i)host key verification failed`),
+		},
+	},
+}
+
+// classifyFailure returns the known reason of a failed fastlane run, if any.
+func classifyFailure(out string, err error) *failureReason {
+	if _, ok := err.(timeoutError); ok {
+		return &failureReason{ExitCode: timeoutExitCode, Result: "failed_timeout", Message: "Command timed out"}
+	}
+
+	for i, reason := range failureReasons {
+		for _, exp := range reason.Patterns {
+			if exp.MatchString(out) {
+				return &failureReasons[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// failFastlane fails the step with the exit code of the classified failure reason,
+// or with 1 if the reason is unknown.
+func failFastlane(out string, err error, format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+
+	printHints(matchingHints(out))
+
+	reason := classifyFailure(out, err)
+	if reason == nil {
+		fail("%s, error: %s", msg, err)
+	}
+
+	log.Errorf("%s, error: %s", msg, err)
+	log.Errorf("Failure reason: %s (exit code: %d)", reason.Message, reason.ExitCode)
+	exportResult(reason.Result, reason.Message)
+
+	if reason.ExitCode == authExitCode || reason.ExitCode == twoFactorExitCode {
+		writeSpaceshipDiagnostics(out)
+	}
+
+	exit(reason.ExitCode)
+}
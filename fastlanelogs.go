@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// collectFastlaneLogs copies the fastlane logs and the fastlane/report.xml of the
+// working directory into the deploy dir, to keep them as build artifacts.
+func collectFastlaneLogs(workDir string) {
+	deployDir := os.Getenv("BITRISE_DEPLOY_DIR")
+	if deployDir == "" {
+		return
+	}
+
+	if workDir == "" {
+		workDir = "."
+	}
+
+	logsDir := filepath.Join(deployDir, "fastlane_logs")
+	sources := map[string]string{
+		filepath.Join(pathutil.UserHomeDir(), "Library", "Logs", "fastlane"): "logs",
+		filepath.Join(workDir, "fastlane", "report.xml"):                     "report.xml",
+	}
+
+	for src, name := range sources {
+		info, exist, err := pathutil.PathCheckAndInfos(src)
+		if err != nil || !exist {
+			continue
+		}
+
+		if err := pathutil.EnsureDirExist(logsDir); err != nil {
+			log.Warnf("Failed to create fastlane logs dir, error: %s", err)
+			return
+		}
+
+		dst := filepath.Join(logsDir, name)
+		if info.IsDir() {
+			err = command.CopyDir(src, dst, true)
+		} else {
+			err = command.CopyFile(src, dst)
+		}
+		if err != nil {
+			log.Warnf("Failed to copy %s, error: %s", src, err)
+			continue
+		}
+
+		fmt.Println()
+		log.Printf("Copied %s to: %s", src, dst)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-tools/go-steputils/cache"
+)
+
+const gemHomeInstalledMarker = ".installed"
+
+// gemHomeCacheKey identifies the gem directory of a fastlane version,
+// the ruby version is part of it as native extensions are built against it.
+func gemHomeCacheKey(fastlaneVersion string) (string, error) {
+	rubyVersion, err := command.New("ruby", "--version").RunAndReturnTrimmedOutput()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha1.Sum([]byte(fastlaneVersion + "\n" + rubyVersion))
+	return fmt.Sprintf("fastlane-%s-%x", fastlaneVersion, sum[:6]), nil
+}
+
+// useIsolatedGemHome points the gem commands of the process to a gem directory dedicated
+// to the fastlane version, and reports whether it holds a complete installation,
+// restored from the cache or left by a previous build on the same machine.
+func useIsolatedGemHome(fastlaneVersion string) (bool, error) {
+	key, err := gemHomeCacheKey(fastlaneVersion)
+	if err != nil {
+		return false, err
+	}
+
+	gemHome := filepath.Join(pathutil.UserHomeDir(), ".match-gems", key)
+	if err := pathutil.EnsureDirExist(gemHome); err != nil {
+		return false, err
+	}
+
+	gemPath, err := command.New("gem", "env", "gempath").RunAndReturnTrimmedOutput()
+	if err != nil {
+		return false, err
+	}
+
+	envs := map[string]string{
+		"GEM_HOME": gemHome,
+		"GEM_PATH": strings.Join([]string{gemHome, gemPath}, string(os.PathListSeparator)),
+		"PATH":     strings.Join([]string{filepath.Join(gemHome, "bin"), os.Getenv("PATH")}, string(os.PathListSeparator)),
+	}
+	for key, value := range envs {
+		if err := os.Setenv(key, value); err != nil {
+			return false, err
+		}
+	}
+
+	return pathutil.IsPathExists(filepath.Join(gemHome, gemHomeInstalledMarker))
+}
+
+// writeFastlaneGemfile writes a Gemfile pinning the fastlane version into a temporary directory,
+// removed on exit, and returns its path.
+func writeFastlaneGemfile(fastlaneVersion, gemSource string) (string, error) {
+	tmpDir, err := pathutil.NormalizedOSTempDirPath("match_gemfile")
+	if err != nil {
+		return "", err
+	}
+	registerCleanup(func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warnf("Failed to remove the generated Gemfile, error: %s", err)
+		}
+	})
+
+	if gemSource == "" {
+		gemSource = defaultGemSource
+	}
+
+	requirement := ""
+	if fastlaneVersion != "latest" {
+		requirement = ", " + rubyString(fastlaneVersion)
+	}
+
+	content := fmt.Sprintf("source %s\n\ngem 'fastlane'%s\n", rubyString(gemSource), requirement)
+
+	pth := filepath.Join(tmpDir, "Gemfile")
+	return pth, fileutil.WriteStringToFile(pth, content)
+}
+
+// markGemHomeInstalled records that the gem directory holds a complete installation.
+func markGemHomeInstalled() error {
+	return fileutil.WriteStringToFile(filepath.Join(os.Getenv("GEM_HOME"), gemHomeInstalledMarker), "")
+}
+
+// cacheGems adds the system gem directory, and the bundle path if bundler is used,
+// to the paths cached by the Cache:Push step.
+func cacheGems(bundlerUsed bool) error {
+	gemCache := cache.New()
+
+	gemDir, err := command.New("gem", "env", "gemdir").RunAndReturnTrimmedOutput()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Caching gem directory: %s", gemDir)
+	gemCache.IncludePath(gemDir)
+
+	if bundlePath := os.Getenv("BUNDLE_PATH"); bundlerUsed && bundlePath != "" {
+		log.Printf("Caching bundle path: %s", bundlePath)
+		gemCache.IncludePath(bundlePath)
+	}
+
+	return gemCache.Commit()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// gemfileSearchDirs are the directories, relative to the source dir, searched for a Gemfile first.
+var gemfileSearchDirs = []string{".", "fastlane", "ios", "ios/App", "app", "mobile", "mobile/ios"}
+
+// gemfileSkipDirs are never searched, they contain the Gemfiles of dependencies.
+var gemfileSkipDirs = map[string]bool{"vendor": true, "node_modules": true, "Pods": true, "Carthage": true, ".git": true}
+
+var fastlaneGemExp = regexp.MustCompile(`(?m)^\s*gem\s*\(?\s*['"]fastlane['"]`)
+
+// declaresFastlane reports whether the Gemfile declares the fastlane gem.
+func declaresFastlane(gemfilePth string) (bool, error) {
+	content, err := fileutil.ReadStringFromFile(gemfilePth)
+	if err != nil {
+		return false, err
+	}
+	return fastlaneGemExp.MatchString(content), nil
+}
+
+// gemfileCandidates returns the Gemfile paths to check under the source dir: the common
+// directories first, then the rest of the directories two levels deep, sorted.
+func gemfileCandidates(sourceDir string) ([]string, error) {
+	candidates := []string{}
+	seen := map[string]bool{}
+	add := func(pth string) {
+		if !seen[pth] {
+			seen[pth] = true
+			candidates = append(candidates, pth)
+		}
+	}
+
+	for _, dir := range gemfileSearchDirs {
+		add(filepath.Join(sourceDir, dir, "Gemfile"))
+	}
+
+	for _, pattern := range []string{"*/Gemfile", "*/*/Gemfile"} {
+		matches, err := filepath.Glob(filepath.Join(sourceDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			rel, err := filepath.Rel(sourceDir, match)
+			if err != nil {
+				return nil, err
+			}
+
+			skip := false
+			for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/") {
+				skip = skip || gemfileSkipDirs[dir]
+			}
+			if !skip {
+				add(match)
+			}
+		}
+	}
+
+	return candidates, nil
+}
+
+// discoverGemfile searches the source dir for a Gemfile declaring fastlane,
+// returns an empty path if none found.
+func discoverGemfile(sourceDir string) (string, error) {
+	if sourceDir == "" {
+		sourceDir = "."
+	}
+
+	candidates, err := gemfileCandidates(sourceDir)
+	if err != nil {
+		return "", err
+	}
+
+	found := []string{}
+	for _, pth := range candidates {
+		if exist, err := pathutil.IsPathExists(pth); err != nil {
+			return "", err
+		} else if !exist {
+			continue
+		}
+
+		if declares, err := declaresFastlane(pth); err != nil {
+			return "", err
+		} else if declares {
+			found = append(found, pth)
+		}
+	}
+
+	if len(found) == 0 {
+		return "", nil
+	}
+
+	log.Printf("Gemfile declaring fastlane found at: %s", found[0])
+	for _, pth := range found[1:] {
+		log.Warnf("Ignoring the Gemfile at: %s, set gemfile_path to use it", pth)
+	}
+	return found[0], nil
+}
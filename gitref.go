@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var commitSHAExp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// isCommitSHA reports whether the git ref is a full commit hash.
+func isCommitSHA(ref string) bool {
+	return commitSHAExp.MatchString(ref)
+}
+
+// storageBranch returns the branch or tag match checks out from the git storage.
+func (configs ConfigsModel) storageBranch() string {
+	if configs.GitRef != "" {
+		return configs.GitRef
+	}
+	return configs.GitBranch
+}
+
+// cloneBranchDirectly reports whether match should clone the branch directly,
+// a tag can't be checked out otherwise.
+func (configs ConfigsModel) cloneBranchDirectly() bool {
+	return configs.CloneBranchDirectly == "yes" || configs.GitRef != ""
+}
+
+// resolveGitRef returns the commit the tag points to, a commit hash is returned as is.
+func resolveGitRef(url, ref, privateKeyPath, basicAuthorization string) (string, error) {
+	if isCommitSHA(ref) {
+		return ref, nil
+	}
+
+	cmd := gitCommand(privateKeyPath, basicAuthorization, "ls-remote", "--tags", url, "refs/tags/"+ref, "refs/tags/"+ref+"^{}")
+
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s, output: %s", gitAccessFailureReason(out), out)
+	}
+
+	// Annotated tags are listed twice, the peeled one points to the commit
+	commit := ""
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[1] == "refs/tags/"+ref+"^{}" || commit == "" {
+			commit = fields[0]
+		}
+	}
+
+	if commit == "" {
+		return "", fmt.Errorf("tag not found: %s", ref)
+	}
+	return commit, nil
+}
+
+// verifyGitHead checks that the clone in the directory is checked out at the commit.
+func verifyGitHead(dir, commit string) error {
+	cmd := gitCommand("", "", "-C", dir, "rev-parse", "HEAD")
+
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to read the checked out commit, output: %s", out)
+	}
+
+	if out != commit {
+		return fmt.Errorf("checked out commit %s, expected %s", out, commit)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// errorHint is an actionable remediation for a known fastlane match error.
+type errorHint struct {
+	Pattern *regexp.Regexp
+	Hint    string
+}
+
+var errorHints = []errorHint{
+	{
+		Pattern: regexp.MustCompile(`(?i)invalid password passed via 'MATCH_PASSWORD'|couldn't decrypt the repo|bad decrypt`),
+		Hint:    "Invalid password - the decrypt_password input (MATCH_PASSWORD) does not match the passphrase the storage was encrypted with.",
+	},
+	{
+		Pattern: regexp.MustCompile("(?i)can not create a new one because you enabled `readonly`"),
+		Hint:    "The storage has no matching certificate or profile - run match once without readonly, or check the app_id, type and team_id inputs.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)permission denied \(publickey\)`),
+		Hint:    "The SSH key has no access to the certificates repository - add the key as a deploy key of the repository, or set git_private_key.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)host key verification failed`),
+		Hint:    "The git host key is not trusted - enable add_known_hosts to add it to the known hosts, and pin the expected keys with ssh_host_fingerprints.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)repository not found`),
+		Hint:    "The git_url is wrong or the credentials can not see the repository.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)two-(factor|step) (authentication|verification)|need to provide the 2FA code`),
+		Hint:    "Apple ID login requires two-factor authentication on CI - use an App Store Connect API key (api_key_path) or a FASTLANE_SESSION.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)invalid username and password combination`),
+		Hint:    "The Apple ID or its password is wrong - check the apple_id and apple_id_password inputs.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)authentication credentials are missing or invalid`),
+		Hint:    "The App Store Connect API key is invalid or revoked - check the key ID, issuer ID and the private key.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)maximum number of (available )?certificates|You already have a current .* certificate`),
+		Hint:    "The team reached its certificate limit - revoke an unused certificate, or run match with readonly.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)multiple profiles found with the name`),
+		Hint:    "Duplicate profile names on the Developer Portal - delete the duplicates or set profile_name.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)Could not find a matching code signing identity|The private key .* is not installed`),
+		Hint:    "The certificate in the storage does not match the portal anymore - re-create it with match nuke, or set skip_certificate_matching.",
+	},
+	{
+		Pattern: regexp.MustCompile(`(?i)SecKeychainItemImport|User interaction is not allowed`),
+		Hint:    "The keychain is locked - set keychain_name and keychain_password, or enable create_keychain.",
+	},
+}
+
+// matchingHints returns the hints of the known errors found in the output.
+func matchingHints(out string) []string {
+	hints := []string{}
+	for _, hint := range errorHints {
+		if hint.Pattern.MatchString(out) {
+			hints = append(hints, hint.Hint)
+		}
+	}
+	return hints
+}
+
+// printHints logs the hints under a "Possible fix" section.
+func printHints(hints []string) {
+	if len(hints) == 0 {
+		return
+	}
+
+	fmt.Println()
+	log.Warnf("Possible fix:")
+	for _, hint := range hints {
+		log.Printf("- %s", hint)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-tools/go-steputils/input"
+)
+
+// validateImport checks the inputs required to import existing signing assets into the storage.
+func (configs ConfigsModel) validateImport() error {
+	if err := input.ValidateWithOptions(configs.Type, "adhoc", "appstore", "development", "enterprise"); err != nil {
+		return fmt.Errorf("Type, %s (a single type is required for import)", err)
+	}
+
+	if err := input.ValidateIfNotEmpty(strings.Join(splitList(configs.AppID), ",")); err != nil {
+		return fmt.Errorf("App ID %s", err)
+	}
+
+	if err := input.ValidateIfPathExists(configs.ImportCertPath); err != nil {
+		return fmt.Errorf("Import cert path %s", err)
+	}
+
+	if err := input.ValidateIfPathExists(configs.ImportP12Path); err != nil {
+		return fmt.Errorf("Import p12 path %s", err)
+	}
+
+	if configs.ImportProfilePath != "" {
+		if err := input.ValidateIfPathExists(configs.ImportProfilePath); err != nil {
+			return fmt.Errorf("Import profile path %s", err)
+		}
+	}
+
+	return nil
+}
+
+// importArgs returns the match import arguments of the imported files.
+func (configs ConfigsModel) importArgs() []string {
+	args := []string{
+		"--app_identifier", strings.Join(splitList(configs.AppID), ","),
+		"--cert_path", configs.ImportCertPath,
+		"--p12_path", configs.ImportP12Path,
+	}
+
+	if configs.ImportProfilePath != "" {
+		args = append(args, "--profile_path", configs.ImportProfilePath)
+	}
+
+	return args
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// installCertificate imports the certificate and its private key (.p12) into the keychain,
+// the default one if keychain is empty, allowing codesign to access the key.
+func installCertificate(cerPth, p12Pth, p12Password, keychain string) error {
+	keychainArgs := []string{}
+	if keychain != "" {
+		keychainArgs = append(keychainArgs, "-k", keychain)
+	}
+
+	if out, err := runSecurity(append([]string{"import", cerPth, "-T", "/usr/bin/codesign"}, keychainArgs...)...); err != nil && !strings.Contains(out, "already exists") {
+		return err
+	}
+
+	if out, err := runSecurity(append([]string{"import", p12Pth, "-P", p12Password, "-T", "/usr/bin/codesign"}, keychainArgs...)...); err != nil && !strings.Contains(out, "already exists") {
+		return err
+	}
+
+	return nil
+}
+
+// setKeyPartitionList lets the Apple tools use the private keys of the keychain without
+// a prompt, which requires the keychain password. Nothing happens if it is not known.
+func setKeyPartitionList(keychain, keychainPassword string) error {
+	if keychain == "" || keychainPassword == "" {
+		return nil
+	}
+
+	_, err := runSecurity("set-key-partition-list", "-S", "apple-tool:,apple:,codesign:", "-s", "-k", keychainPassword, keychain)
+	return err
+}
+
+// codesignCheckTimeout limits the test signature, codesign waits for a prompt if the key is not accessible.
+const codesignCheckTimeout = 30 * time.Second
+
+// verifyCodesignIdentities checks that the certificates are valid codesigning identities of the keychain,
+// and that their private keys can be used by codesign without a prompt, by signing a temporary file.
+func verifyCodesignIdentities(keychain string, sha1s []string) error {
+	out, err := runSecurity("find-identity", "-v", "-p", "codesigning", keychain)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := pathutil.NormalizedOSTempDirPath("codesign_check")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warnf("Failed to remove temporary directory, error: %s", err)
+		}
+	}()
+
+	unusable := []string{}
+	for _, sha1 := range sha1s {
+		if !strings.Contains(out, sha1) {
+			unusable = append(unusable, sha1+" (not a valid identity)")
+			continue
+		}
+
+		pth := filepath.Join(tmpDir, sha1)
+		if err := fileutil.WriteStringToFile(pth, "codesign check\n"); err != nil {
+			return err
+		}
+
+		cmd := command.New("codesign", "--force", "--sign", sha1, "--keychain", keychain, pth)
+		if err := runWithTimeout(cmd.GetCmd(), codesignCheckTimeout); err != nil {
+			unusable = append(unusable, fmt.Sprintf("%s (%s)", sha1, err))
+		}
+	}
+
+	if len(unusable) > 0 {
+		return fmt.Errorf("identities not usable by codesign: %s", strings.Join(unusable, ", "))
+	}
+	return nil
+}
+
+// installProfile copies the provisioning profile, named after its UUID,
+// to every directory Xcode reads the profiles from.
+func installProfile(pth string) (ProfileModel, error) {
+	profile, err := parseProfile(pth)
+	if err != nil {
+		return ProfileModel{}, err
+	}
+
+	content, err := fileutil.ReadBytesFromFile(pth)
+	if err != nil {
+		return ProfileModel{}, err
+	}
+
+	for _, dir := range provisioningProfileDirs {
+		if err := pathutil.EnsureDirExist(dir); err != nil {
+			return ProfileModel{}, err
+		}
+
+		if err := fileutil.WriteBytesToFile(filepath.Join(dir, profile.UUID+filepath.Ext(pth)), content); err != nil {
+			return ProfileModel{}, err
+		}
+	}
+
+	log.Printf("Installed provisioning profile: %s (%s)", profile.Name, profile.UUID)
+	return profile, nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func runSecurity(args ...string) (string, error) {
+	out, err := command.New("security", args...).RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("security %s failed, output: %s, error: %s", args[0], out, err)
+	}
+	return out, nil
+}
+
+// userKeychains returns the keychain search list of the user.
+func userKeychains() ([]string, error) {
+	out, err := runSecurity("list-keychains", "-d", "user")
+	if err != nil {
+		return nil, err
+	}
+
+	keychains := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if keychain := strings.Trim(strings.TrimSpace(line), `"`); keychain != "" {
+			keychains = append(keychains, keychain)
+		}
+	}
+	return keychains, nil
+}
+
+// createEphemeralKeychain creates an unlocked keychain with a random name and password,
+// and adds it to the search list. It is kept for the later signing steps of the build,
+// the cleanup command deletes it.
+func createEphemeralKeychain() (string, string, error) {
+	suffix, err := randomHex(8)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	pth := filepath.Join(pathutil.UserHomeDir(), "Library", "Keychains", fmt.Sprintf("match-%s.keychain-db", suffix))
+
+	searchList, err := userKeychains()
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := runSecurity("create-keychain", "-p", password, pth); err != nil {
+		return "", "", err
+	}
+
+	if _, err := runSecurity(append([]string{"list-keychains", "-d", "user", "-s", pth}, searchList...)...); err != nil {
+		return "", "", err
+	}
+
+	if _, err := runSecurity("set-keychain-settings", pth); err != nil {
+		return "", "", err
+	}
+
+	if _, err := runSecurity("unlock-keychain", "-p", password, pth); err != nil {
+		return "", "", err
+	}
+
+	return pth, password, nil
+}
+
+// deleteKeychain deletes the keychain file and removes it from the search list.
+func deleteKeychain(pth string) error {
+	_, err := runSecurity("delete-keychain", pth)
+	return err
+}
+
+// keychainSettingsModel are the lock settings of a keychain, a zero timeout means it never locks.
+type keychainSettingsModel struct {
+	LockOnSleep bool
+	Timeout     int
+}
+
+var keychainTimeoutExp = regexp.MustCompile(`timeout=(\d+)s`)
+
+// keychainSettings reads the lock settings of the keychain.
+func keychainSettings(keychain string) (keychainSettingsModel, error) {
+	out, err := runSecurity("show-keychain-info", keychain)
+	if err != nil {
+		return keychainSettingsModel{}, err
+	}
+
+	settings := keychainSettingsModel{LockOnSleep: strings.Contains(out, "lock-on-sleep")}
+	if match := keychainTimeoutExp.FindStringSubmatch(out); match != nil {
+		settings.Timeout, _ = strconv.Atoi(match[1])
+	}
+	return settings, nil
+}
+
+// exportKeychainSettingsOutputs exports the lock settings of the keychain before the step changes them.
+func exportKeychainSettingsOutputs(keychain string, settings keychainSettingsModel) error {
+	lockOnSleep := "no"
+	if settings.LockOnSleep {
+		lockOnSleep = "yes"
+	}
+
+	if err := exportOutput("MATCH_KEYCHAIN_LOCK_SETTINGS_PATH", keychain); err != nil {
+		return err
+	}
+	if err := exportOutput("MATCH_KEYCHAIN_PREVIOUS_LOCK_TIMEOUT", strconv.Itoa(settings.Timeout)); err != nil {
+		return err
+	}
+	return exportOutput("MATCH_KEYCHAIN_PREVIOUS_LOCK_ON_SLEEP", lockOnSleep)
+}
+
+// previousKeychainSettings reads the lock settings an earlier fetch exported.
+func previousKeychainSettings() (keychainSettingsModel, error) {
+	timeout, err := strconv.Atoi(os.Getenv("MATCH_KEYCHAIN_PREVIOUS_LOCK_TIMEOUT"))
+	if err != nil {
+		return keychainSettingsModel{}, fmt.Errorf("invalid MATCH_KEYCHAIN_PREVIOUS_LOCK_TIMEOUT, error: %s", err)
+	}
+	return keychainSettingsModel{
+		Timeout:     timeout,
+		LockOnSleep: os.Getenv("MATCH_KEYCHAIN_PREVIOUS_LOCK_ON_SLEEP") == "yes",
+	}, nil
+}
+
+// setKeychainSettings applies the lock settings to the keychain.
+func setKeychainSettings(keychain string, settings keychainSettingsModel) error {
+	args := []string{"set-keychain-settings"}
+	if settings.LockOnSleep {
+		args = append(args, "-l")
+	}
+	if settings.Timeout > 0 {
+		args = append(args, "-u", "-t", strconv.Itoa(settings.Timeout))
+	}
+	args = append(args, keychain)
+
+	_, err := runSecurity(args...)
+	return err
+}
+
+var certificateSHA1Exp = regexp.MustCompile(`(?m)^SHA-1 hash: ([0-9A-F]{40})$`)
+
+// keychainCertificateSHA1s returns the SHA-1 fingerprints of the certificates in the keychain,
+// or in the keychain search list if no keychain is given.
+func keychainCertificateSHA1s(keychain string) (map[string]bool, error) {
+	args := []string{"find-certificate", "-a", "-Z"}
+	if keychain != "" {
+		args = append(args, keychain)
+	}
+
+	sha1s := map[string]bool{}
+	out, err := runSecurity(args...)
+	if err != nil {
+		// An empty keychain has no certificate to find
+		if strings.Contains(out, "could not be found") {
+			return sha1s, nil
+		}
+		return nil, err
+	}
+
+	for _, match := range certificateSHA1Exp.FindAllStringSubmatch(out, -1) {
+		sha1s[match[1]] = true
+	}
+	return sha1s, nil
+}
+
+// defaultKeychain returns the path of the user's default keychain.
+func defaultKeychain() (string, error) {
+	out, err := runSecurity("default-keychain", "-d", "user")
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(strings.TrimSpace(out), `"`), nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+var scpLikeGitURLExp = regexp.MustCompile(`^(?:[\w.-]+@)?([\w.-]+):`)
+
+// sshHost returns the host and the port of an SSH git url, the port is empty for the default one.
+func sshHost(gitURL string) (string, string, error) {
+	if strings.HasPrefix(gitURL, "ssh://") {
+		u, err := url.Parse(gitURL)
+		if err != nil {
+			return "", "", err
+		}
+		return u.Hostname(), u.Port(), nil
+	}
+
+	if match := scpLikeGitURLExp.FindStringSubmatch(gitURL); match != nil {
+		return match[1], "", nil
+	}
+	return "", "", fmt.Errorf("not an SSH git url: %s", gitURL)
+}
+
+// knownHostsName returns the host the way known_hosts refers to it.
+func knownHostsName(host, port string) string {
+	if port == "" || port == "22" {
+		return host
+	}
+	return fmt.Sprintf("[%s]:%s", host, port)
+}
+
+// sshKeyFingerprint returns the SHA256 fingerprint of a known_hosts line, as ssh-keygen -l prints it.
+func sshKeyFingerprint(line string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return "", fmt.Errorf("invalid host key: %s", line)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(key)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// provisionKnownHosts adds the host keys of the git host to ~/.ssh/known_hosts.
+// If expected fingerprints are given, only the matching keys are added and the host is
+// scanned even if already known, otherwise the keys are trusted on first use.
+func provisionKnownHosts(gitURL string, expectedFingerprints []string) error {
+	host, port, err := sshHost(gitURL)
+	if err != nil {
+		return err
+	}
+	name := knownHostsName(host, port)
+
+	if len(expectedFingerprints) == 0 {
+		if out, err := command.New("ssh-keygen", "-F", name).RunAndReturnTrimmedCombinedOutput(); err == nil && out != "" {
+			log.Printf("%s is already a known host", name)
+			return nil
+		}
+	}
+
+	args := []string{"-T", "10"}
+	if port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, host)
+
+	out, err := command.New("ssh-keyscan", args...).RunAndReturnTrimmedOutput()
+	if err != nil || out == "" {
+		return fmt.Errorf("failed to scan the host keys of %s, output: %s", name, out)
+	}
+
+	expected := map[string]bool{}
+	for _, fingerprint := range expectedFingerprints {
+		expected[fingerprint] = true
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fingerprint, err := sshKeyFingerprint(line)
+		if err != nil {
+			return err
+		}
+
+		if len(expected) > 0 && !expected[fingerprint] {
+			log.Warnf("Skipping unexpected host key of %s: %s", name, fingerprint)
+			continue
+		}
+
+		log.Printf("Adding host key of %s: %s", name, fingerprint)
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return errors.New("none of the host keys match the expected fingerprints")
+	}
+	if len(expectedFingerprints) == 0 {
+		log.Warnf("The host keys were trusted on first use, set ssh_host_fingerprints to pin them")
+	}
+
+	sshDir := filepath.Join(pathutil.UserHomeDir(), ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		return err
+	}
+
+	pth := filepath.Join(sshDir, "known_hosts")
+	content := ""
+	if exist, err := pathutil.IsPathExists(pth); err != nil {
+		return err
+	} else if exist {
+		if content, err = fileutil.ReadStringFromFile(pth); err != nil {
+			return err
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+	}
+
+	for _, line := range lines {
+		if !strings.Contains(content, line) {
+			content += line + "\n"
+		}
+	}
+
+	return fileutil.WriteStringToFileWithPermission(pth, content, 0600)
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bitrise-io/go-steputils/command/rubycommand"
 	"github.com/bitrise-io/go-utils/command"
-	"github.com/bitrise-io/go-utils/command/rubycommand"
 	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/pathutil"
@@ -21,12 +25,107 @@ import (
 
 // ConfigsModel ...
 type ConfigsModel struct {
-	GitURL          string
-	GitBranch       string
-	AppID           string
-	DecryptPassword string
-	Type            string
-	TeamID          string
+	GitURL                       string
+	GitBranch                    string
+	AppID                        string
+	DecryptPassword              string
+	Type                         string
+	TeamID                       string
+	TeamName                     string
+	Readonly                     string
+	AllowCreateAssets            string
+	Force                        string
+	ForceForNewDevices           string
+	GenerateAppleCerts           string
+	APIKeyPath                   string
+	APIKeyContentBase64          string
+	AppleID                      string
+	AppleIDPassword              string
+	FastlaneSession              string
+	KeychainName                 string
+	KeychainPassword             string
+	SkipSetPartitionList         string
+	KeychainLockTimeout          string
+	KeychainLockOnSleep          string
+	StorageMode                  string
+	S3Bucket                     string
+	S3Region                     string
+	S3ObjectPrefix               string
+	S3Endpoint                   string
+	S3ForcePathStyle             string
+	S3AccessKey                  string
+	S3SecretAccessKey            string
+	GoogleCloudBucketName        string
+	GoogleCloudKeysFile          string
+	GoogleCloudKeysContent       string
+	GoogleCloudProjectID         string
+	GitBasicAuthorization        string
+	GitPrivateKey                string
+	ShallowClone                 string
+	CloneBranchDirectly          string
+	SkipProvisioningProfiles     string
+	SkipCertificateMatching      string
+	TemplateName                 string
+	ProfileName                  string
+	OutputPath                   string
+	ExportToDeployDir            string
+	SkipDocs                     string
+	Verbose                      string
+	IncludeMacInProfiles         string
+	AdditionalCertTypes          string
+	FailOnNameTaken              string
+	DeriveCatalystAppIdentifier  string
+	APIKeyID                     string
+	APIKeyIssuerID               string
+	APIKeyP8Content              string
+	APIKeyDuration               string
+	APIKeyInHouse                string
+	GitFullName                  string
+	GitUserEmail                 string
+	GitlabProject                string
+	GitlabHost                   string
+	GitlabJobToken               string
+	GitlabPrivateToken           string
+	Command                      string
+	NukeType                     string
+	NukeConfirmation             string
+	ImportCertPath               string
+	ImportP12Path                string
+	ImportProfilePath            string
+	NewDecryptPassword           string
+	DevicesFile                  string
+	Devices                      string
+	CopyProfilesToDeployDir      string
+	GenerateExportOptions        string
+	GenerateXcconfig             string
+	GenerateSummary              string
+	ExpiryFailThresholdDays      string
+	ExpiryThresholdAction        string
+	CertificateExpiryWarningDays string
+	ProjectPath                  string
+	Scheme                       string
+	Platform                     string
+	CreateKeychain               string
+	AppIdentifierTypes           string
+	MatchMatrix                  string
+	MatchfilePath                string
+	WorkingDirectory             string
+	GenerateMatchfile            string
+	UseAppleServiceConnection    string
+	GemSource                    string
+	RubyVersion                  string
+	CacheGems                    string
+	RetryCount                   string
+	RetryWaitSeconds             string
+	CommandTimeout               string
+	Debug                        string
+	DryRun                       string
+	FetchEngine                  string
+	EntitlementsCheck            string
+	GitRef                       string
+	AddKnownHosts                string
+	SSHHostFingerprints          string
+	GitDeployKey                 string
 
 	Options         string
 	GemfilePath     string
@@ -35,12 +134,107 @@ type ConfigsModel struct {
 
 func createConfigsModelFromEnvs() ConfigsModel {
 	return ConfigsModel{
-		GitURL:          os.Getenv("git_url"),
-		GitBranch:       os.Getenv("git_branch"),
-		AppID:           os.Getenv("app_id"),
-		DecryptPassword: os.Getenv("decrypt_password"),
-		Type:            os.Getenv("type"),
-		TeamID:          os.Getenv("team_id"),
+		GitURL:                       os.Getenv("git_url"),
+		GitBranch:                    os.Getenv("git_branch"),
+		AppID:                        os.Getenv("app_id"),
+		DecryptPassword:              os.Getenv("decrypt_password"),
+		Type:                         os.Getenv("type"),
+		TeamID:                       os.Getenv("team_id"),
+		TeamName:                     os.Getenv("team_name"),
+		Readonly:                     os.Getenv("readonly"),
+		AllowCreateAssets:            os.Getenv("allow_create_assets"),
+		Force:                        os.Getenv("force"),
+		ForceForNewDevices:           os.Getenv("force_for_new_devices"),
+		GenerateAppleCerts:           os.Getenv("generate_apple_certs"),
+		APIKeyPath:                   os.Getenv("api_key_path"),
+		APIKeyContentBase64:          os.Getenv("api_key_content_base64"),
+		AppleID:                      os.Getenv("apple_id"),
+		AppleIDPassword:              os.Getenv("apple_id_password"),
+		FastlaneSession:              os.Getenv("fastlane_session"),
+		KeychainName:                 os.Getenv("keychain_name"),
+		KeychainPassword:             os.Getenv("keychain_password"),
+		SkipSetPartitionList:         os.Getenv("skip_set_partition_list"),
+		KeychainLockTimeout:          os.Getenv("keychain_lock_timeout"),
+		KeychainLockOnSleep:          os.Getenv("keychain_lock_on_sleep"),
+		StorageMode:                  os.Getenv("storage_mode"),
+		S3Bucket:                     os.Getenv("s3_bucket"),
+		S3Region:                     os.Getenv("s3_region"),
+		S3ObjectPrefix:               os.Getenv("s3_object_prefix"),
+		S3Endpoint:                   os.Getenv("s3_endpoint"),
+		S3ForcePathStyle:             os.Getenv("s3_force_path_style"),
+		S3AccessKey:                  os.Getenv("s3_access_key"),
+		S3SecretAccessKey:            os.Getenv("s3_secret_access_key"),
+		GoogleCloudBucketName:        os.Getenv("google_cloud_bucket_name"),
+		GoogleCloudKeysFile:          os.Getenv("google_cloud_keys_file"),
+		GoogleCloudKeysContent:       os.Getenv("google_cloud_keys_content"),
+		GoogleCloudProjectID:         os.Getenv("google_cloud_project_id"),
+		GitBasicAuthorization:        os.Getenv("git_basic_authorization"),
+		GitPrivateKey:                os.Getenv("git_private_key"),
+		ShallowClone:                 os.Getenv("shallow_clone"),
+		CloneBranchDirectly:          os.Getenv("clone_branch_directly"),
+		SkipProvisioningProfiles:     os.Getenv("skip_provisioning_profiles"),
+		SkipCertificateMatching:      os.Getenv("skip_certificate_matching"),
+		TemplateName:                 os.Getenv("template_name"),
+		ProfileName:                  os.Getenv("profile_name"),
+		OutputPath:                   os.Getenv("output_path"),
+		ExportToDeployDir:            os.Getenv("export_to_deploy_dir"),
+		SkipDocs:                     os.Getenv("skip_docs"),
+		Verbose:                      os.Getenv("verbose"),
+		IncludeMacInProfiles:         os.Getenv("include_mac_in_profiles"),
+		AdditionalCertTypes:          os.Getenv("additional_cert_types"),
+		FailOnNameTaken:              os.Getenv("fail_on_name_taken"),
+		DeriveCatalystAppIdentifier:  os.Getenv("derive_catalyst_app_identifier"),
+		APIKeyID:                     os.Getenv("api_key_id"),
+		APIKeyIssuerID:               os.Getenv("api_key_issuer_id"),
+		APIKeyP8Content:              os.Getenv("api_key_p8_content"),
+		APIKeyDuration:               os.Getenv("api_key_duration"),
+		APIKeyInHouse:                os.Getenv("api_key_in_house"),
+		GitFullName:                  os.Getenv("git_full_name"),
+		GitUserEmail:                 os.Getenv("git_user_email"),
+		GitlabProject:                os.Getenv("gitlab_project"),
+		GitlabHost:                   os.Getenv("gitlab_host"),
+		GitlabJobToken:               os.Getenv("gitlab_job_token"),
+		GitlabPrivateToken:           os.Getenv("gitlab_private_token"),
+		Command:                      os.Getenv("command"),
+		NukeType:                     os.Getenv("nuke_type"),
+		NukeConfirmation:             os.Getenv("nuke_confirmation"),
+		ImportCertPath:               os.Getenv("import_cert_path"),
+		ImportP12Path:                os.Getenv("import_p12_path"),
+		ImportProfilePath:            os.Getenv("import_profile_path"),
+		NewDecryptPassword:           os.Getenv("new_decrypt_password"),
+		DevicesFile:                  os.Getenv("devices_file"),
+		Devices:                      os.Getenv("devices"),
+		CopyProfilesToDeployDir:      os.Getenv("copy_profiles_to_deploy_dir"),
+		GenerateExportOptions:        os.Getenv("generate_export_options"),
+		GenerateXcconfig:             os.Getenv("generate_xcconfig"),
+		GenerateSummary:              os.Getenv("generate_summary"),
+		ExpiryFailThresholdDays:      os.Getenv("expiry_fail_threshold_days"),
+		ExpiryThresholdAction:        os.Getenv("expiry_threshold_action"),
+		CertificateExpiryWarningDays: os.Getenv("certificate_expiry_warning_days"),
+		ProjectPath:                  os.Getenv("project_path"),
+		Scheme:                       os.Getenv("scheme"),
+		Platform:                     os.Getenv("platform"),
+		CreateKeychain:               os.Getenv("create_keychain"),
+		AppIdentifierTypes:           os.Getenv("app_identifier_types"),
+		MatchMatrix:                  os.Getenv("match_matrix"),
+		MatchfilePath:                os.Getenv("matchfile_path"),
+		WorkingDirectory:             os.Getenv("working_directory"),
+		GenerateMatchfile:            os.Getenv("generate_matchfile"),
+		UseAppleServiceConnection:    os.Getenv("use_apple_service_connection"),
+		GemSource:                    os.Getenv("gem_source"),
+		RubyVersion:                  os.Getenv("ruby_version"),
+		CacheGems:                    os.Getenv("cache_gems"),
+		RetryCount:                   os.Getenv("retry_count"),
+		RetryWaitSeconds:             os.Getenv("retry_wait_seconds"),
+		CommandTimeout:               os.Getenv("command_timeout"),
+		Debug:                        os.Getenv("debug"),
+		DryRun:                       os.Getenv("dry_run"),
+		FetchEngine:                  os.Getenv("fetch_engine"),
+		EntitlementsCheck:            os.Getenv("entitlements_check"),
+		GitRef:                       os.Getenv("git_ref"),
+		AddKnownHosts:                os.Getenv("add_known_hosts"),
+		SSHHostFingerprints:          os.Getenv("ssh_host_fingerprints"),
+		GitDeployKey:                 os.Getenv("git_deploy_key"),
 
 		Options:         os.Getenv("options"),
 		GemfilePath:     os.Getenv("gemfile_path"),
@@ -57,6 +251,101 @@ func (configs ConfigsModel) print() {
 	log.Printf("- DecryptPassword: %s", input.SecureInput(configs.DecryptPassword))
 	log.Printf("- Type: %s", configs.Type)
 	log.Printf("- TeamID: %s", configs.TeamID)
+	log.Printf("- TeamName: %s", configs.TeamName)
+	log.Printf("- Readonly: %s", configs.Readonly)
+	log.Printf("- AllowCreateAssets: %s", configs.AllowCreateAssets)
+	log.Printf("- Force: %s", configs.Force)
+	log.Printf("- ForceForNewDevices: %s", configs.ForceForNewDevices)
+	log.Printf("- GenerateAppleCerts: %s", configs.GenerateAppleCerts)
+	log.Printf("- APIKeyPath: %s", configs.APIKeyPath)
+	log.Printf("- APIKeyContentBase64: %s", input.SecureInput(configs.APIKeyContentBase64))
+	log.Printf("- AppleID: %s", configs.AppleID)
+	log.Printf("- AppleIDPassword: %s", input.SecureInput(configs.AppleIDPassword))
+	log.Printf("- FastlaneSession: %s", input.SecureInput(configs.FastlaneSession))
+	log.Printf("- KeychainName: %s", configs.KeychainName)
+	log.Printf("- KeychainPassword: %s", input.SecureInput(configs.KeychainPassword))
+	log.Printf("- SkipSetPartitionList: %s", configs.SkipSetPartitionList)
+	log.Printf("- KeychainLockTimeout: %s", configs.KeychainLockTimeout)
+	log.Printf("- KeychainLockOnSleep: %s", configs.KeychainLockOnSleep)
+	log.Printf("- StorageMode: %s", configs.StorageMode)
+	log.Printf("- S3Bucket: %s", configs.S3Bucket)
+	log.Printf("- S3Region: %s", configs.S3Region)
+	log.Printf("- S3ObjectPrefix: %s", configs.S3ObjectPrefix)
+	log.Printf("- S3Endpoint: %s", configs.S3Endpoint)
+	log.Printf("- S3ForcePathStyle: %s", configs.S3ForcePathStyle)
+	log.Printf("- S3AccessKey: %s", input.SecureInput(configs.S3AccessKey))
+	log.Printf("- S3SecretAccessKey: %s", input.SecureInput(configs.S3SecretAccessKey))
+	log.Printf("- GoogleCloudBucketName: %s", configs.GoogleCloudBucketName)
+	log.Printf("- GoogleCloudKeysFile: %s", configs.GoogleCloudKeysFile)
+	log.Printf("- GoogleCloudKeysContent: %s", input.SecureInput(configs.GoogleCloudKeysContent))
+	log.Printf("- GoogleCloudProjectID: %s", configs.GoogleCloudProjectID)
+	log.Printf("- GitBasicAuthorization: %s", input.SecureInput(configs.GitBasicAuthorization))
+	log.Printf("- GitPrivateKey: %s", input.SecureInput(configs.GitPrivateKey))
+	log.Printf("- ShallowClone: %s", configs.ShallowClone)
+	log.Printf("- CloneBranchDirectly: %s", configs.CloneBranchDirectly)
+	log.Printf("- SkipProvisioningProfiles: %s", configs.SkipProvisioningProfiles)
+	log.Printf("- SkipCertificateMatching: %s", configs.SkipCertificateMatching)
+	log.Printf("- TemplateName: %s", configs.TemplateName)
+	log.Printf("- ProfileName: %s", configs.ProfileName)
+	log.Printf("- OutputPath: %s", configs.OutputPath)
+	log.Printf("- ExportToDeployDir: %s", configs.ExportToDeployDir)
+	log.Printf("- SkipDocs: %s", configs.SkipDocs)
+	log.Printf("- Verbose: %s", configs.Verbose)
+	log.Printf("- IncludeMacInProfiles: %s", configs.IncludeMacInProfiles)
+	log.Printf("- AdditionalCertTypes: %s", configs.AdditionalCertTypes)
+	log.Printf("- FailOnNameTaken: %s", configs.FailOnNameTaken)
+	log.Printf("- DeriveCatalystAppIdentifier: %s", configs.DeriveCatalystAppIdentifier)
+	log.Printf("- APIKeyID: %s", configs.APIKeyID)
+	log.Printf("- APIKeyIssuerID: %s", configs.APIKeyIssuerID)
+	log.Printf("- APIKeyP8Content: %s", input.SecureInput(configs.APIKeyP8Content))
+	log.Printf("- APIKeyDuration: %s", configs.APIKeyDuration)
+	log.Printf("- APIKeyInHouse: %s", configs.APIKeyInHouse)
+	log.Printf("- GitFullName: %s", configs.GitFullName)
+	log.Printf("- GitUserEmail: %s", configs.GitUserEmail)
+	log.Printf("- GitlabProject: %s", configs.GitlabProject)
+	log.Printf("- GitlabHost: %s", configs.GitlabHost)
+	log.Printf("- GitlabJobToken: %s", input.SecureInput(configs.GitlabJobToken))
+	log.Printf("- GitlabPrivateToken: %s", input.SecureInput(configs.GitlabPrivateToken))
+	log.Printf("- Command: %s", configs.Command)
+	log.Printf("- NukeType: %s", configs.NukeType)
+	log.Printf("- NukeConfirmation: %s", configs.NukeConfirmation)
+	log.Printf("- ImportCertPath: %s", configs.ImportCertPath)
+	log.Printf("- ImportP12Path: %s", configs.ImportP12Path)
+	log.Printf("- ImportProfilePath: %s", configs.ImportProfilePath)
+	log.Printf("- NewDecryptPassword: %s", input.SecureInput(configs.NewDecryptPassword))
+	log.Printf("- DevicesFile: %s", configs.DevicesFile)
+	log.Printf("- Devices: %s", configs.Devices)
+	log.Printf("- CopyProfilesToDeployDir: %s", configs.CopyProfilesToDeployDir)
+	log.Printf("- GenerateExportOptions: %s", configs.GenerateExportOptions)
+	log.Printf("- GenerateXcconfig: %s", configs.GenerateXcconfig)
+	log.Printf("- GenerateSummary: %s", configs.GenerateSummary)
+	log.Printf("- ExpiryFailThresholdDays: %s", configs.ExpiryFailThresholdDays)
+	log.Printf("- ExpiryThresholdAction: %s", configs.ExpiryThresholdAction)
+	log.Printf("- CertificateExpiryWarningDays: %s", configs.CertificateExpiryWarningDays)
+	log.Printf("- ProjectPath: %s", configs.ProjectPath)
+	log.Printf("- Scheme: %s", configs.Scheme)
+	log.Printf("- Platform: %s", configs.Platform)
+	log.Printf("- CreateKeychain: %s", configs.CreateKeychain)
+	log.Printf("- AppIdentifierTypes: %s", configs.AppIdentifierTypes)
+	log.Printf("- MatchMatrix: %s", configs.MatchMatrix)
+	log.Printf("- MatchfilePath: %s", configs.MatchfilePath)
+	log.Printf("- WorkingDirectory: %s", configs.WorkingDirectory)
+	log.Printf("- GenerateMatchfile: %s", configs.GenerateMatchfile)
+	log.Printf("- UseAppleServiceConnection: %s", configs.UseAppleServiceConnection)
+	log.Printf("- GemSource: %s", configs.GemSource)
+	log.Printf("- RubyVersion: %s", configs.RubyVersion)
+	log.Printf("- CacheGems: %s", configs.CacheGems)
+	log.Printf("- RetryCount: %s", configs.RetryCount)
+	log.Printf("- RetryWaitSeconds: %s", configs.RetryWaitSeconds)
+	log.Printf("- CommandTimeout: %s", configs.CommandTimeout)
+	log.Printf("- Debug: %s", configs.Debug)
+	log.Printf("- DryRun: %s", configs.DryRun)
+	log.Printf("- FetchEngine: %s", configs.FetchEngine)
+	log.Printf("- EntitlementsCheck: %s", configs.EntitlementsCheck)
+	log.Printf("- GitRef: %s", configs.GitRef)
+	log.Printf("- AddKnownHosts: %s", configs.AddKnownHosts)
+	log.Printf("- SSHHostFingerprints: %s", configs.SSHHostFingerprints)
+	log.Printf("- GitDeployKey: %s", input.SecureInput(configs.GitDeployKey))
 
 	log.Printf("- Options: %s", configs.Options)
 	log.Printf("- GemfilePath: %s", configs.GemfilePath)
@@ -64,257 +353,481 @@ func (configs ConfigsModel) print() {
 }
 
 func (configs ConfigsModel) validate() error {
-	if err := input.ValidateIfNotEmpty(configs.GitURL); err != nil {
-		return fmt.Errorf("Git Url %s", err)
+	if err := input.ValidateWithOptions(configs.Command, "fetch", "nuke", "import", "change_password", "decrypt", "cleanup"); err != nil {
+		return fmt.Errorf("Command, %s", err)
 	}
 
-	if err := input.ValidateIfNotEmpty(configs.AppID); err != nil {
-		return fmt.Errorf("App ID %s", err)
+	if configs.MatchfilePath != "" {
+		if err := input.ValidateIfPathExists(configs.MatchfilePath); err != nil {
+			return fmt.Errorf("Matchfile path %s", err)
+		}
 	}
 
-	if err := input.ValidateIfNotEmpty(configs.DecryptPassword); err != nil {
-		return fmt.Errorf("Decrypt Password %s", err)
+	if configs.WorkingDirectory != "" {
+		if err := input.ValidateIfDirExists(configs.WorkingDirectory); err != nil {
+			return fmt.Errorf("Working directory %s", err)
+		}
+
+		if configs.MatchfilePath != "" {
+			workingDir, err := filepath.Abs(configs.WorkingDirectory)
+			if err != nil {
+				return fmt.Errorf("Working directory %s", err)
+			}
+
+			if matchfileDir, err := matchfileWorkDir(configs.MatchfilePath); err != nil {
+				return fmt.Errorf("Matchfile path %s", err)
+			} else if matchfileDir != workingDir {
+				return errors.New("Matchfile path has to be in the working directory or in its fastlane directory")
+			}
+		}
 	}
 
-	if err := input.ValidateWithOptions(configs.Type, "adhoc", "appstore", "development", "enterprise"); err != nil {
-		return fmt.Errorf("Type, %s", err)
+	if configs.Command != "cleanup" && !configs.usesMatchfileStorage() {
+		if err := configs.validateStorage(); err != nil {
+			return err
+		}
 	}
 
-	return nil
-}
+	switch configs.Command {
+	case "fetch":
+		if err := configs.validateFetch(); err != nil {
+			return err
+		}
+	case "nuke":
+		if err := configs.validateNuke(); err != nil {
+			return err
+		}
+	case "import":
+		if err := configs.validateImport(); err != nil {
+			return err
+		}
+	case "change_password":
+		if err := configs.validateChangePassword(); err != nil {
+			return err
+		}
+	case "decrypt":
+		if err := configs.validateDecrypt(); err != nil {
+			return err
+		}
+	}
 
-func fail(format string, v ...interface{}) {
-	log.Errorf(format, v...)
-	os.Exit(1)
-}
+	if err := input.ValidateWithOptions(configs.Readonly, "yes", "no"); err != nil {
+		return fmt.Errorf("Readonly, %s", err)
+	}
 
-func gemInstallWithRetry(gemName string, version string) error {
-	return retry.Times(2).Try(func(attempt uint) error {
-		if attempt > 0 {
-			log.Warnf("%d attempt failed", attempt+1)
-		}
+	if err := input.ValidateWithOptions(configs.Force, "yes", "no"); err != nil {
+		return fmt.Errorf("Force, %s", err)
+	}
 
-		versionToInstall := version
+	if err := input.ValidateWithOptions(configs.AllowCreateAssets, "yes", "no"); err != nil {
+		return fmt.Errorf("Allow create assets, %s", err)
+	}
 
-		if versionToInstall == "latest" {
-			versionToInstall = ""
-		}
+	if err := input.ValidateWithOptions(configs.ForceForNewDevices, "yes", "no"); err != nil {
+		return fmt.Errorf("Force for new devices, %s", err)
+	}
 
-		cmds, err := rubycommand.GemInstall(gemName, versionToInstall)
-		if err != nil {
-			return fmt.Errorf("Failed to create command, error: %s", err)
+	if err := input.ValidateWithOptions(configs.GenerateAppleCerts, "default", "yes", "no"); err != nil {
+		return fmt.Errorf("Generate Apple certs, %s", err)
+	}
+
+	if configs.APIKeyPath != "" && !isRemoteURL(configs.APIKeyPath) {
+		if err := input.ValidateIfPathExists(configs.APIKeyPath); err != nil {
+			return fmt.Errorf("API key path %s", err)
 		}
+	}
 
-		for _, cmd := range cmds {
-			if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
-				return fmt.Errorf("Gem install failed, output: %s, error: %s", out, err)
-			}
+	apiKeySources := 0
+	for _, source := range []string{configs.APIKeyPath, configs.APIKeyContentBase64, configs.APIKeyID} {
+		if source != "" {
+			apiKeySources++
 		}
+	}
+	if apiKeySources > 1 {
+		return errors.New("Only one of API key path, API key content and API key ID can be set")
+	}
 
-		return nil
-	})
-}
+	if configs.APIKeyDuration != "" {
+		if duration, err := strconv.Atoi(configs.APIKeyDuration); err != nil || duration <= 0 || duration > maxAPIKeyDuration {
+			return fmt.Errorf("API key duration, invalid parameter: %s, must be an integer between 1 and %d", configs.APIKeyDuration, maxAPIKeyDuration)
+		}
+	}
 
-func gemVersionFromGemfileLockContent(gem, content string) string {
-	relevantLines := []string{}
-	lines := strings.Split(content, "\n")
+	if err := input.ValidateWithOptions(configs.APIKeyInHouse, "yes", "no"); err != nil {
+		return fmt.Errorf("API key in house, %s", err)
+	}
 
-	specsStart := false
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
-			break
+	if configs.APIKeyID != "" || configs.APIKeyIssuerID != "" || configs.APIKeyP8Content != "" {
+		if err := input.ValidateIfNotEmpty(configs.APIKeyID); err != nil {
+			return fmt.Errorf("API key ID %s", err)
 		}
 
-		if trimmed == "specs:" {
-			specsStart = true
-			continue
+		if err := input.ValidateIfNotEmpty(configs.APIKeyIssuerID); err != nil {
+			return fmt.Errorf("API key issuer ID %s", err)
 		}
 
-		if specsStart {
-			relevantLines = append(relevantLines, trimmed)
+		if err := input.ValidateIfNotEmpty(configs.APIKeyP8Content); err != nil {
+			return fmt.Errorf("API key p8 content %s", err)
 		}
 	}
 
-	exp := regexp.MustCompile(fmt.Sprintf(`^%s \((.+)\)`, gem))
-	for _, line := range relevantLines {
-		match := exp.FindStringSubmatch(line)
-		if match != nil && len(match) == 2 {
-			return match[1]
-		}
+	if configs.AppleIDPassword != "" && configs.AppleID == "" {
+		return errors.New("Apple ID password is set but Apple ID is not specified")
 	}
 
-	return ""
-}
+	if (configs.KeychainName == "") != (configs.KeychainPassword == "") {
+		return errors.New("Keychain name and Keychain password must be provided together")
+	}
 
-func gemVersionFromGemfileLock(gem, gemfileLockPth string) (string, error) {
-	content, err := fileutil.ReadStringFromFile(gemfileLockPth)
-	if err != nil {
-		return "", err
+	if configs.GemSource != "" && !isRemoteURL(configs.GemSource) {
+		return fmt.Errorf("Gem source, not a http(s) url: %s", configs.GemSource)
 	}
-	return gemVersionFromGemfileLockContent(gem, content), nil
-}
 
-func ensureFastlaneVersionAndCreateCmdSlice(forceVersion, gemfilePth string) ([]string, string, error) {
-	if forceVersion != "" {
-		log.Printf("fastlane version defined: %s, installing...", forceVersion)
+	if err := input.ValidateWithOptions(configs.SkipSetPartitionList, "yes", "no"); err != nil {
+		return fmt.Errorf("Skip set partition list, %s", err)
+	}
 
-		newVersion := forceVersion
-		if forceVersion == "latest" {
-			newVersion = ""
+	if configs.KeychainLockTimeout != "" {
+		if timeout, err := strconv.Atoi(configs.KeychainLockTimeout); err != nil || timeout < 0 {
+			return fmt.Errorf("Keychain lock timeout, invalid parameter: %s, must be a non-negative integer", configs.KeychainLockTimeout)
 		}
+	}
 
-		if err := gemInstallWithRetry("fastlane", newVersion); err != nil {
-			return nil, "", err
+	if err := input.ValidateWithOptions(configs.KeychainLockOnSleep, "keep", "yes", "no"); err != nil {
+		return fmt.Errorf("Keychain lock on sleep, %s", err)
+	}
+
+	if err := input.ValidateWithOptions(configs.CacheGems, "yes", "no"); err != nil {
+		return fmt.Errorf("Cache gems, %s", err)
+	}
+
+	if err := input.ValidateWithOptions(configs.FetchEngine, "fastlane", "native"); err != nil {
+		return fmt.Errorf("Fetch engine, %s", err)
+	}
+
+	if configs.FetchEngine == "native" {
+		if configs.Command != "fetch" || configs.StorageMode != "git" || configs.Readonly != "yes" || configs.usesMatchfileStorage() {
+			return errors.New("Fetch engine, native is only available for the fetch command with the git storage in readonly mode")
 		}
 
-		fastlaneCmdSlice := []string{"fastlane"}
-		if newVersion != "" {
-			fastlaneCmdSlice = append(fastlaneCmdSlice, fmt.Sprintf("_%s_", newVersion))
+		if configs.Devices != "" || configs.DevicesFile != "" {
+			return errors.New("Fetch engine, native can not register devices")
 		}
+	}
 
-		return fastlaneCmdSlice, "", nil
+	if err := input.ValidateWithOptions(configs.Debug, "yes", "no"); err != nil {
+		return fmt.Errorf("Debug, %s", err)
 	}
 
-	if gemfilePth == "" {
-		log.Printf("no fastlane version nor Gemfile path defined, using system installed fastlane...")
-		return []string{"fastlane"}, "", nil
+	if err := input.ValidateWithOptions(configs.DryRun, "yes", "no"); err != nil {
+		return fmt.Errorf("Dry run, %s", err)
 	}
 
-	if exist, err := pathutil.IsPathExists(gemfilePth); err != nil {
-		return nil, "", err
-	} else if !exist {
-		log.Printf("Gemfile not exist at: %s and no fastlane version defined, using system installed fastlane...", gemfilePth)
-		return []string{"fastlane"}, "", nil
+	if err := input.ValidateWithOptions(configs.UseAppleServiceConnection, "yes", "no"); err != nil {
+		return fmt.Errorf("Use Apple service connection, %s", err)
 	}
 
-	log.Printf("Gemfile exist, checking fastlane version from Gemfile.lock")
+	if err := input.ValidateWithOptions(configs.AddKnownHosts, "yes", "no"); err != nil {
+		return fmt.Errorf("Add known hosts, %s", err)
+	}
 
-	gemfileDir := filepath.Dir(gemfilePth)
-	gemfileLockPth := filepath.Join(gemfileDir, "Gemfile.lock")
+	if err := input.ValidateWithOptions(configs.ShallowClone, "yes", "no"); err != nil {
+		return fmt.Errorf("Shallow clone, %s", err)
+	}
 
-	bundleInstallCalled := false
-	if exist, err := pathutil.IsPathExists(gemfileLockPth); err != nil {
-		return nil, "", err
-	} else if !exist {
-		log.Printf("Gemfile.lock not exist at: %s, running 'bundle install' ...", gemfileLockPth)
+	if err := input.ValidateWithOptions(configs.CloneBranchDirectly, "yes", "no"); err != nil {
+		return fmt.Errorf("Clone branch directly, %s", err)
+	}
 
-		cmd := command.NewWithStandardOuts("bundle", "install").SetStdin(os.Stdin).SetDir(gemfileDir)
-		if err := cmd.Run(); err != nil {
-			return nil, "", err
+	if err := input.ValidateWithOptions(configs.SkipProvisioningProfiles, "yes", "no"); err != nil {
+		return fmt.Errorf("Skip provisioning profiles, %s", err)
+	}
+
+	if err := input.ValidateWithOptions(configs.SkipCertificateMatching, "yes", "no"); err != nil {
+		return fmt.Errorf("Skip certificate matching, %s", err)
+	}
+
+	if configs.SkipCertificateMatching == "yes" && configs.SkipProvisioningProfiles == "yes" {
+		return errors.New("Skip certificate matching and Skip provisioning profiles can not be enabled at the same time")
+	}
+
+	if configs.TemplateName != "" && configs.Command != "fetch" {
+		return fmt.Errorf("Template name can only be used with the fetch command, %s doesn't generate provisioning profiles", configs.Command)
+	}
+
+	if configs.TemplateName != "" && configs.SkipProvisioningProfiles == "yes" {
+		return errors.New("Template name can not be used when Skip provisioning profiles is enabled")
+	}
+
+	if err := input.ValidateWithOptions(configs.ExportToDeployDir, "yes", "no"); err != nil {
+		return fmt.Errorf("Export to deploy dir, %s", err)
+	}
+
+	if configs.ExportToDeployDir == "yes" && configs.OutputPath == "" {
+		return errors.New("Export to deploy dir requires Output path to be set")
+	}
+
+	if err := input.ValidateWithOptions(configs.SkipDocs, "yes", "no"); err != nil {
+		return fmt.Errorf("Skip docs, %s", err)
+	}
+
+	if err := input.ValidateWithOptions(configs.Verbose, "yes", "no"); err != nil {
+		return fmt.Errorf("Verbose, %s", err)
+	}
+
+	if err := input.ValidateWithOptions(configs.IncludeMacInProfiles, "yes", "no"); err != nil {
+		return fmt.Errorf("Include Mac in profiles, %s", err)
+	}
+
+	for _, certType := range splitList(configs.AdditionalCertTypes) {
+		if err := input.ValidateWithOptions(certType, "mac_installer_distribution", "developer_id_installer"); err != nil {
+			return fmt.Errorf("Additional cert types, %s", err)
 		}
+	}
 
-		bundleInstallCalled = true
+	if err := input.ValidateWithOptions(configs.FailOnNameTaken, "yes", "no"); err != nil {
+		return fmt.Errorf("Fail on name taken, %s", err)
+	}
 
-		if exist, err := pathutil.IsPathExists(gemfileLockPth); err != nil {
-			return nil, "", err
-		} else if !exist {
-			return nil, "", errors.New("Gemfile.lock does not exist, even 'bundle install' was called")
+	if err := input.ValidateWithOptions(configs.DeriveCatalystAppIdentifier, "yes", "no"); err != nil {
+		return fmt.Errorf("Derive Catalyst app identifier, %s", err)
+	}
+
+	if configs.ExpiryFailThresholdDays != "" {
+		if days, err := strconv.Atoi(configs.ExpiryFailThresholdDays); err != nil || days < 0 {
+			return fmt.Errorf("Expiry fail threshold days, invalid parameter: %s, must be a non-negative integer", configs.ExpiryFailThresholdDays)
 		}
 	}
 
-	fastlaneVersion, err := gemVersionFromGemfileLock("fastlane", gemfileLockPth)
-	if err != nil {
-		return nil, "", err
+	if configs.CommandTimeout != "" {
+		if seconds, err := strconv.Atoi(configs.CommandTimeout); err != nil || seconds < 0 {
+			return fmt.Errorf("Command timeout, invalid parameter: %s, must be a non-negative integer", configs.CommandTimeout)
+		}
 	}
 
-	if fastlaneVersion != "" {
-		log.Printf("fastlane version defined in Gemfile.lock: %s, using bundler to call fastlane commands...", fastlaneVersion)
+	if configs.RetryCount != "" {
+		if count, err := strconv.Atoi(configs.RetryCount); err != nil || count < 0 {
+			return fmt.Errorf("Retry count, invalid parameter: %s, must be a non-negative integer", configs.RetryCount)
+		}
+	}
 
-		if !bundleInstallCalled {
-			cmd := command.NewWithStandardOuts("bundle", "install").SetStdin(os.Stdin).SetDir(gemfileDir)
-			if err := cmd.Run(); err != nil {
-				return nil, "", err
-			}
+	if configs.RetryWaitSeconds != "" {
+		if seconds, err := strconv.Atoi(configs.RetryWaitSeconds); err != nil || seconds < 0 {
+			return fmt.Errorf("Retry wait seconds, invalid parameter: %s, must be a non-negative integer", configs.RetryWaitSeconds)
+		}
+	}
+
+	if configs.CertificateExpiryWarningDays != "" {
+		if days, err := strconv.Atoi(configs.CertificateExpiryWarningDays); err != nil || days < 0 {
+			return fmt.Errorf("Certificate expiry warning days, invalid parameter: %s, must be a non-negative integer", configs.CertificateExpiryWarningDays)
 		}
+	}
 
-		return []string{"bundle", "exec", "fastlane"}, gemfileDir, nil
+	if err := input.ValidateWithOptions(configs.ExpiryThresholdAction, "fail", "warn"); err != nil {
+		return fmt.Errorf("Expiry threshold action, %s", err)
 	}
 
-	log.Printf("fastlane version not found in Gemfile.lock, using system installed fastlane...")
+	if err := input.ValidateWithOptions(configs.EntitlementsCheck, "off", "warn", "fail"); err != nil {
+		return fmt.Errorf("Entitlements check, %s", err)
+	}
 
-	return []string{"fastlane"}, "", nil
+	if err := input.ValidateWithOptions(configs.Platform, "auto", "ios", "macos", "tvos", "catalyst"); err != nil {
+		return fmt.Errorf("Platform, %s", err)
+	}
+
+	return nil
 }
 
-func main() {
-	configs := createConfigsModelFromEnvs()
+// validateFetch checks the inputs required to fetch certificates and profiles.
+func (configs ConfigsModel) validateFetch() error {
+	types := []string{}
+	generatesProfiles := configs.Readonly != "yes"
 
-	fmt.Println()
-	configs.print()
+	if configs.MatchMatrix != "" && configs.AppIdentifierTypes != "" {
+		return errors.New("Match matrix can not be used together with App identifier types")
+	}
 
-	if err := configs.validate(); err != nil {
-		fail("Issue with input: %s", err)
+	if configs.MatchMatrix != "" {
+		groups, err := parseMatchMatrix(configs.MatchMatrix)
+		if err != nil {
+			return fmt.Errorf("Match matrix, %s", err)
+		}
+
+		for _, group := range groups {
+			types = append(types, group.Type)
+		}
+		generatesProfiles = len(writableGroups(groups, configs.Readonly == "yes")) > 0
+	} else if configs.AppIdentifierTypes != "" {
+		groups, err := parseAppIdentifierTypes(configs.AppIdentifierTypes)
+		if err != nil {
+			return fmt.Errorf("App identifier types, %s", err)
+		}
+
+		for _, group := range groups {
+			types = append(types, group.Type)
+		}
+	} else {
+		if configs.ProjectPath != "" {
+			if err := input.ValidateIfPathExists(configs.ProjectPath); err != nil {
+				return fmt.Errorf("Project path %s", err)
+			}
+		} else if configs.MatchfilePath == "" {
+			// Otherwise app_identifier can be defined in the Matchfile
+			if err := input.ValidateIfNotEmpty(strings.Join(splitList(configs.AppID), ",")); err != nil {
+				return fmt.Errorf("App ID %s", err)
+			}
+		}
+
+		types = splitList(configs.Type)
+		if err := input.ValidateIfNotEmpty(strings.Join(types, ",")); err != nil {
+			return fmt.Errorf("Type %s", err)
+		}
+
+		for _, matchType := range types {
+			if err := input.ValidateWithOptions(matchType, "adhoc", "appstore", "development", "enterprise"); err != nil {
+				return fmt.Errorf("Type, %s", err)
+			}
+		}
 	}
 
-	//
-	// Setup
-	fmt.Println()
-	log.Infof("Setup")
+	// Every profile type accepts a template, but match only applies it to the profiles it generates
+	if configs.TemplateName != "" && !generatesProfiles {
+		return errors.New("Template name is only applied to generated provisioning profiles, disable readonly for at least one type")
+	}
 
-	startTime := time.Now()
+	if configs.ForceForNewDevices == "yes" {
+		supported := false
+		for _, matchType := range types {
+			if supportsForceForNewDevices(matchType) {
+				supported = true
+			}
+		}
+		if !supported {
+			return fmt.Errorf("Force for new devices, only available for adhoc and development types")
+		}
+	}
 
-	fastlaneCmdSlice, workDir, err := ensureFastlaneVersionAndCreateCmdSlice(configs.FastlaneVersion, configs.GemfilePath)
-	if err != nil {
-		fail("Failed to ensure fastlane version, error: %s", err)
+	if err := configs.validateDevices(types); err != nil {
+		return err
 	}
 
-	versionCmdSlice := append(fastlaneCmdSlice, "-v")
-	versionCmd := command.NewWithStandardOuts(versionCmdSlice[0], versionCmdSlice[1:]...)
-	log.Printf("$ %s", versionCmd.PrintableCommandArgs())
-	if err := versionCmd.Run(); err != nil {
-		fail("Failed to print fastlane version, error: %s", err)
+	if err := input.ValidateWithOptions(configs.CopyProfilesToDeployDir, "yes", "no"); err != nil {
+		return fmt.Errorf("Copy profiles to deploy dir, %s", err)
 	}
 
-	elapsed := time.Since(startTime)
+	if err := input.ValidateWithOptions(configs.GenerateExportOptions, "yes", "no"); err != nil {
+		return fmt.Errorf("Generate export options, %s", err)
+	}
 
-	log.Printf("Setup took %f seconds to complete", elapsed.Seconds())
+	if err := input.ValidateWithOptions(configs.GenerateXcconfig, "yes", "no"); err != nil {
+		return fmt.Errorf("Generate xcconfig, %s", err)
+	}
 
-	//
-	// Main
-	fmt.Println()
-	log.Infof("Running Match")
+	if err := input.ValidateWithOptions(configs.GenerateSummary, "yes", "no"); err != nil {
+		return fmt.Errorf("Generate summary, %s", err)
+	}
 
-	fmt.Println()
+	if err := input.ValidateWithOptions(configs.CreateKeychain, "yes", "no"); err != nil {
+		return fmt.Errorf("Create keychain, %s", err)
+	}
 
-	options := []string{}
-	if configs.Options != "" {
-		opts, err := shellquote.Split(configs.Options)
-		if err != nil {
-			fail("Failed to split options (%s), error: %s", configs.Options, err)
-		}
-		options = opts
+	if configs.CreateKeychain == "yes" && configs.KeychainName != "" {
+		return errors.New("Create keychain can not be used together with Keychain name")
 	}
 
-	envs := []string{
-		fmt.Sprintf("MATCH_PASSWORD=%s", configs.DecryptPassword),
+	if err := input.ValidateWithOptions(configs.GenerateMatchfile, "yes", "no"); err != nil {
+		return fmt.Errorf("Generate Matchfile, %s", err)
 	}
 
-	args := []string{
-		"match",
-		configs.Type,
-		"--readonly",
+	return nil
+}
+
+func supportsForceForNewDevices(matchType string) bool {
+	return matchType == "adhoc" || matchType == "development"
+}
+
+// sensitiveFlags are the fastlane options whose values are never printed,
+// even if they are passed in the free-form options input.
+var sensitiveFlags = map[string]bool{
+	"--git_basic_authorization":  true,
+	"--git_bearer_authorization": true,
+	"--keychain_password":        true,
+	"--s3_access_key":            true,
+	"--s3_secret_access_key":     true,
+	"--job_token":                true,
+	"--private_token":            true,
+	"--password":                 true,
+}
+
+// maskedCommandArgs returns the printable form of the command, with the values
+// of the sensitive flags and every argument equal to one of the secrets replaced by ***.
+func maskedCommandArgs(cmdSlice []string, secrets ...string) string {
+	masked := make([]string, len(cmdSlice))
+	for i, arg := range cmdSlice {
+		masked[i] = arg
+
+		if i > 0 && sensitiveFlags[cmdSlice[i-1]] {
+			masked[i] = input.SecureInput(arg)
+			continue
+		}
+
+		if split := strings.SplitN(arg, "=", 2); len(split) == 2 && sensitiveFlags[split[0]] {
+			masked[i] = split[0] + "=" + input.SecureInput(split[1])
+			continue
+		}
+
+		for _, secret := range secrets {
+			if secret != "" && arg == secret {
+				masked[i] = input.SecureInput(arg)
+			}
+		}
 	}
+	return command.PrintableCommandArgs(false, masked)
+}
 
-	args = append(args, "--git_url", configs.GitURL)
-	args = append(args, "--app_identifier", configs.AppID)
+// authArgs returns the Apple Developer Portal authentication arguments.
+func (configs ConfigsModel) authArgs(apiKeyPath string) []string {
+	args := []string{}
 
-	if configs.GitBranch != "" {
-		args = append(args, "--git_branch", configs.GitBranch)
+	if apiKeyPath != "" {
+		args = append(args, "--api_key_path", apiKeyPath)
 	}
-	
+
+	if configs.AppleID != "" {
+		args = append(args, "--username", configs.AppleID)
+	}
+
 	if configs.TeamID != "" {
 		args = append(args, "--team_id", configs.TeamID)
-	}	
+	}
 
-	args = append(args, options...)
+	if configs.TeamName != "" {
+		args = append(args, "--team_name", configs.TeamName)
+	}
+
+	return args
+}
 
-	cmdSlice := append(fastlaneCmdSlice, args...)
+// runFastlane runs fastlane with the given arguments and returns its combined output,
+// the secrets are masked in the printed command.
+func runFastlane(fastlaneCmdSlice []string, workDir string, args, envs, secrets []string) (string, error) {
+	cmdSlice := append(append([]string{}, fastlaneCmdSlice...), args...)
 
 	cmd := command.New(cmdSlice[0], cmdSlice[1:]...)
-	log.Donef("$ %s", cmd.PrintableCommandArgs())
+	log.Donef("$ %s", maskedCommandArgs(cmdSlice, secrets...))
+
+	if debugMode || dryRun {
+		logDebugCommand(cmdSlice, workDir, envs, secrets)
+	}
+
+	if dryRun {
+		log.Warnf("Dry run, skipping the command")
+		return "", nil
+	}
 
-	cmd.SetStdout(os.Stdout)
-	cmd.SetStderr(os.Stderr)
+	var output bytes.Buffer
+	cmd.SetStdout(io.MultiWriter(os.Stdout, &output))
+	cmd.SetStderr(io.MultiWriter(os.Stderr, &output))
 	cmd.SetStdin(os.Stdin)
 	cmd.AppendEnvs(envs...)
 	if workDir != "" {
@@ -323,9 +836,1563 @@ func main() {
 
 	fmt.Println()
 
-	if err := cmd.Run(); err != nil {
-		fail("Download or installation failed, error: %s", err)
+	err := runWithTimeout(cmd.GetCmd(), commandTimeout)
+	return output.String(), err
+}
+
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
+
+var cleanupFuncs []func()
+
+// registerCleanup adds a function to be called before the step exits,
+// both on success and on failure.
+func registerCleanup(fn func()) {
+	cleanupFuncs = append(cleanupFuncs, fn)
+}
+
+func cleanup() {
+	for i := len(cleanupFuncs) - 1; i >= 0; i-- {
+		cleanupFuncs[i]()
 	}
+	cleanupFuncs = nil
+}
+
+var failureFuncs []func()
+
+// registerFailureHandler adds a function to be called if the step fails, before the cleanups.
+func registerFailureHandler(fn func()) {
+	failureFuncs = append(failureFuncs, fn)
+}
 
+func fail(format string, v ...interface{}) {
+	log.Errorf(format, v...)
+	exportResult("failed", fmt.Sprintf(format, v...))
+	exit(1)
+}
+
+// exit prints the phase summary and runs the failure handlers and the cleanups before exiting with the code.
+func exit(code int) {
+	printPhaseSummary("failed")
+	for _, fn := range failureFuncs {
+		fn()
+	}
+	cleanup()
+	os.Exit(code)
+}
+
+// isGemVersionInstalled reports whether the exact version of the gem is installed.
+func isGemVersionInstalled(gemName, version string) (bool, error) {
+	out, err := command.New("gem", "list", gemName, "-i", "-v", version).RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		// gem list -i exits with 1 if the gem is not installed
+		if out == "false" {
+			return false, nil
+		}
+		return false, fmt.Errorf("gem list failed, output: %s, error: %s", out, err)
+	}
+	return out == "true", nil
+}
+
+// gemInstallWithRetry installs the gem, from the given source only if it is not empty.
+// Installing a specific version is skipped if it is already present.
+func gemInstallWithRetry(gemName string, version string, source string) error {
+	if version != "" && version != "latest" {
+		if installed, err := isGemVersionInstalled(gemName, version); err != nil {
+			log.Warnf("Failed to check if %s %s is installed, error: %s", gemName, version, err)
+		} else if installed {
+			log.Printf("%s %s is already installed", gemName, version)
+			return nil
+		}
+	}
+
+	return retry.Times(2).Try(func(attempt uint) error {
+		if attempt > 0 {
+			log.Warnf("%d attempt failed", attempt+1)
+		}
+
+		versionToInstall := version
+
+		if versionToInstall == "latest" {
+			versionToInstall = ""
+		}
+
+		cmds, err := rubycommand.GemInstall(gemName, versionToInstall, false)
+		if err != nil {
+			return fmt.Errorf("Failed to create command, error: %s", err)
+		}
+
+		if source != "" {
+			installCmd := cmds[0].GetCmd()
+			installCmd.Args = append(installCmd.Args, "--clear-sources", "--source", source)
+		}
+
+		for _, cmd := range cmds {
+			if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+				return fmt.Errorf("Gem install failed, output: %s, error: %s", out, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// bundleInstallWithRetry installs the gems of the Gemfile set in BUNDLE_GEMFILE,
+// only from the installed gems if local is set.
+func bundleInstallWithRetry(local bool) error {
+	args := []string{"install"}
+	if local {
+		args = append(args, "--local")
+	}
+
+	return retry.Times(2).Try(func(attempt uint) error {
+		if attempt > 0 {
+			log.Warnf("%d attempt failed", attempt+1)
+		}
+
+		if out, err := command.New("bundle", args...).RunAndReturnTrimmedCombinedOutput(); err != nil {
+			return fmt.Errorf("Bundle install failed, output: %s, error: %s", out, err)
+		}
+
+		return nil
+	})
+}
+
+func gemVersionFromGemfileLockContent(gem, content string) string {
+	relevantLines := []string{}
+	lines := strings.Split(content, "\n")
+
+	specsStart := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			break
+		}
+
+		if trimmed == "specs:" {
+			specsStart = true
+			continue
+		}
+
+		if specsStart {
+			relevantLines = append(relevantLines, trimmed)
+		}
+	}
+
+	exp := regexp.MustCompile(fmt.Sprintf(`^%s \((.+)\)`, gem))
+	for _, line := range relevantLines {
+		match := exp.FindStringSubmatch(line)
+		if match != nil && len(match) == 2 {
+			return match[1]
+		}
+	}
+
+	return ""
+}
+
+// bundlerVersionFromGemfileLockContent returns the bundler version listed in the BUNDLED WITH section.
+func bundlerVersionFromGemfileLockContent(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "BUNDLED WITH" && i+1 < len(lines) {
+			return strings.TrimSpace(lines[i+1])
+		}
+	}
+	return ""
+}
+
+func bundlerVersionFromGemfileLock(gemfileLockPth string) (string, error) {
+	content, err := fileutil.ReadStringFromFile(gemfileLockPth)
+	if err != nil {
+		return "", err
+	}
+	return bundlerVersionFromGemfileLockContent(content), nil
+}
+
+func gemVersionFromGemfileLock(gem, gemfileLockPth string) (string, error) {
+	content, err := fileutil.ReadStringFromFile(gemfileLockPth)
+	if err != nil {
+		return "", err
+	}
+	return gemVersionFromGemfileLockContent(gem, content), nil
+}
+
+func ensureFastlaneVersionAndCreateCmdSlice(forceVersion, gemfilePth, gemSource string, installed bool) ([]string, string, error) {
+	if forceVersion != "" {
+		gemfilePth, err := writeFastlaneGemfile(forceVersion, gemSource)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to write the Gemfile of fastlane %s, error: %s", forceVersion, err)
+		}
+
+		// Set for the whole process, so fastlane runs with the generated Gemfile from any directory
+		if err := os.Setenv("BUNDLE_GEMFILE", gemfilePth); err != nil {
+			return nil, "", err
+		}
+
+		if installed {
+			log.Printf("fastlane version defined: %s, using the installed gems...", forceVersion)
+		} else {
+			log.Printf("fastlane version defined: %s, installing with bundler...", forceVersion)
+		}
+
+		if err := bundleInstallWithRetry(installed); err != nil {
+			return nil, "", err
+		}
+
+		return []string{"bundle", "exec", "fastlane"}, "", nil
+	}
+
+	if gemfilePth == "" {
+		log.Printf("no fastlane version nor Gemfile path defined, using system installed fastlane...")
+		return []string{"fastlane"}, "", nil
+	}
+
+	if exist, err := pathutil.IsPathExists(gemfilePth); err != nil {
+		return nil, "", err
+	} else if !exist {
+		log.Printf("Gemfile not exist at: %s and no fastlane version defined, using system installed fastlane...", gemfilePth)
+		return []string{"fastlane"}, "", nil
+	}
+
+	log.Printf("Gemfile exist, checking fastlane version from Gemfile.lock")
+
+	gemfileDir := filepath.Dir(gemfilePth)
+	gemfileLockPth := filepath.Join(gemfileDir, "Gemfile.lock")
+
+	bundleInstallCalled := false
+	if exist, err := pathutil.IsPathExists(gemfileLockPth); err != nil {
+		return nil, "", err
+	} else if !exist {
+		log.Printf("Gemfile.lock not exist at: %s, running 'bundle install' ...", gemfileLockPth)
+
+		cmd := command.NewWithStandardOuts("bundle", "install").SetStdin(os.Stdin).SetDir(gemfileDir)
+		if err := cmd.Run(); err != nil {
+			return nil, "", err
+		}
+
+		bundleInstallCalled = true
+
+		if exist, err := pathutil.IsPathExists(gemfileLockPth); err != nil {
+			return nil, "", err
+		} else if !exist {
+			return nil, "", errors.New("Gemfile.lock does not exist, even 'bundle install' was called")
+		}
+	}
+
+	fastlaneVersion, err := gemVersionFromGemfileLock("fastlane", gemfileLockPth)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if fastlaneVersion != "" {
+		log.Printf("fastlane version defined in Gemfile.lock: %s, using bundler to call fastlane commands...", fastlaneVersion)
+
+		bundleCmdSlice := []string{"bundle"}
+
+		bundlerVersion, err := bundlerVersionFromGemfileLock(gemfileLockPth)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if bundlerVersion != "" {
+			log.Printf("bundler version defined in Gemfile.lock: %s, installing...", bundlerVersion)
+
+			if err := gemInstallWithRetry("bundler", bundlerVersion, gemSource); err != nil {
+				return nil, "", err
+			}
+
+			bundleCmdSlice = append(bundleCmdSlice, fmt.Sprintf("_%s_", bundlerVersion))
+		}
+
+		if !bundleInstallCalled {
+			cmd := command.NewWithStandardOuts(bundleCmdSlice[0], append(bundleCmdSlice[1:], "install")...).SetStdin(os.Stdin).SetDir(gemfileDir)
+			if err := cmd.Run(); err != nil {
+				return nil, "", err
+			}
+		}
+
+		return append(bundleCmdSlice, "exec", "fastlane"), gemfileDir, nil
+	}
+
+	log.Printf("fastlane version not found in Gemfile.lock, using system installed fastlane...")
+
+	return []string{"fastlane"}, "", nil
+}
+
+// setupFastlane installs the required fastlane version and returns the command to call it,
+// the directory to call it from and the installed version.
+func (configs ConfigsModel) setupFastlane() ([]string, string, string) {
+	if configs.RubyVersion != "" {
+		version, err := selectRuby(configs.RubyVersion)
+		if err != nil {
+			fail("Failed to select Ruby %s, error: %s", configs.RubyVersion, err)
+		}
+
+		if !strings.HasPrefix(version, configs.RubyVersion) {
+			fail("Ruby %s is active instead of %s, make sure the shims of the version manager are on the PATH", version, configs.RubyVersion)
+		}
+	}
+
+	// Without a version fastlane runs from the Gemfile, the latest version is installed if no Gemfile declares it
+	usesGemfile := false
+	if configs.FastlaneVersion == "" && configs.GemfilePath != "" {
+		if exist, err := pathutil.IsPathExists(configs.GemfilePath); err != nil {
+			fail("Failed to check Gemfile, error: %s", err)
+		} else if exist {
+			declares, err := declaresFastlane(configs.GemfilePath)
+			if err != nil {
+				fail("Failed to read Gemfile, error: %s", err)
+			}
+			usesGemfile = declares
+		}
+	}
+	if configs.FastlaneVersion == "" && !usesGemfile {
+		log.Printf("No Gemfile declaring fastlane found, using the latest fastlane version")
+		configs.FastlaneVersion = "latest"
+	}
+
+	if err := configs.checkRubyCompatibility(); err != nil {
+		fail("Ruby compatibility check failed, error: %s", err)
+	}
+
+	if configs.CacheGems == "yes" && usesGemfile && os.Getenv("BUNDLE_PATH") == "" {
+		bundlePath, err := pathutil.AbsPath(filepath.Join(filepath.Dir(configs.GemfilePath), "vendor", "bundle"))
+		if err != nil {
+			fail("Failed to expand bundle path, error: %s", err)
+		}
+
+		// Set for the whole process, bundle install and bundle exec has to use the same path
+		if err := os.Setenv("BUNDLE_PATH", bundlePath); err != nil {
+			fail("Failed to set BUNDLE_PATH, error: %s", err)
+		}
+	}
+
+	// A forced fastlane version is installed with bundler into a gem directory dedicated to it,
+	// so the gem versions installed by other steps can't conflict with it
+	gemCacheHit := false
+	if configs.FastlaneVersion != "" {
+		hit, err := useIsolatedGemHome(configs.FastlaneVersion)
+		if err != nil {
+			fail("Failed to prepare the gem directory of fastlane, error: %s", err)
+		}
+
+		if hit && configs.FastlaneVersion != "latest" {
+			log.Printf("fastlane %s is already installed, skipping install", configs.FastlaneVersion)
+			gemCacheHit = true
+		}
+	}
+
+	fastlaneCmdSlice, workDir, err := ensureFastlaneVersionAndCreateCmdSlice(configs.FastlaneVersion, configs.GemfilePath, configs.GemSource, gemCacheHit)
+	if err != nil {
+		fail("Failed to ensure fastlane version, error: %s", err)
+	}
+
+	// Plugins are loaded by bundler, so they are only installed if fastlane runs from the Gemfile
+	if workDir != "" {
+		pluginfilePth := filepath.Join(workDir, "fastlane", "Pluginfile")
+		if exist, err := pathutil.IsPathExists(pluginfilePth); err != nil {
+			fail("Failed to check Pluginfile, error: %s", err)
+		} else if exist {
+			log.Printf("Pluginfile found at: %s, installing fastlane plugins...", pluginfilePth)
+
+			installPluginsCmdSlice := append(append([]string{}, fastlaneCmdSlice...), "install_plugins")
+			cmd := command.NewWithStandardOuts(installPluginsCmdSlice[0], installPluginsCmdSlice[1:]...).SetStdin(os.Stdin).SetDir(workDir)
+			log.Printf("$ %s", cmd.PrintableCommandArgs())
+			if err := cmd.Run(); err != nil {
+				fail("Failed to install fastlane plugins, error: %s", err)
+			}
+		}
+	}
+
+	if configs.FastlaneVersion != "" && configs.FastlaneVersion != "latest" && !gemCacheHit {
+		if err := markGemHomeInstalled(); err != nil {
+			log.Warnf("Failed to mark the gem directory as installed, error: %s", err)
+		}
+	}
+
+	if configs.CacheGems == "yes" {
+		if err := cacheGems(workDir != ""); err != nil {
+			log.Warnf("Failed to add the gems to the cache, error: %s", err)
+		}
+	}
+
+	versionCmdSlice := append(fastlaneCmdSlice, "-v")
+	versionCmd := command.New(versionCmdSlice[0], versionCmdSlice[1:]...)
+	log.Printf("$ %s", versionCmd.PrintableCommandArgs())
+	versionOut, err := versionCmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		fail("Failed to print fastlane version, output: %s, error: %s", versionOut, err)
+	}
+	log.Printf("%s", versionOut)
+
+	return fastlaneCmdSlice, workDir, fastlaneVersionFromOutput(versionOut)
+}
+
+func main() {
+	defer cleanup()
+
+	configs := createConfigsModelFromEnvs()
+
+	fmt.Println()
+	configs.print()
+
+	if err := configs.validate(); err != nil {
+		fail("Issue with input: %s", err)
+	}
+
+	if configs.Command == "cleanup" {
+		fmt.Println()
+		log.Infof("Removing installed signing assets")
+
+		// The temporary keychain of the fetch is deleted with its identities
+		temporaryKeychain := os.Getenv("MATCH_KEYCHAIN_PATH")
+		keychain := configs.KeychainName
+		if keychain == "" {
+			keychain = temporaryKeychain
+		}
+		if keychain == "" {
+			keychain = os.Getenv("BITRISE_KEYCHAIN_PATH")
+		}
+
+		if err := removeInstalledAssets(keychain); err != nil {
+			fail("Failed to remove installed signing assets, error: %s", err)
+		}
+
+		if settingsKeychain := os.Getenv("MATCH_KEYCHAIN_LOCK_SETTINGS_PATH"); settingsKeychain != "" && settingsKeychain != temporaryKeychain {
+			settings, err := previousKeychainSettings()
+			if err != nil {
+				fail("Failed to read the previous keychain settings, error: %s", err)
+			}
+
+			log.Printf("Restoring the keychain lock timeout to %ds, lock on sleep: %t", settings.Timeout, settings.LockOnSleep)
+			if err := setKeychainSettings(settingsKeychain, settings); err != nil {
+				fail("Failed to restore the keychain settings, error: %s", err)
+			}
+		}
+
+		if temporaryKeychain != "" {
+			log.Printf("Deleting temporary keychain: %s", temporaryKeychain)
+
+			if err := deleteKeychain(temporaryKeychain); err != nil {
+				fail("Failed to delete temporary keychain, error: %s", err)
+			}
+		}
+
+		fmt.Println()
+		log.Donef("Success")
+		return
+	}
+
+	//
+	// Setup
+	fmt.Println()
+	log.Infof("Setup")
+
+	timeoutSeconds, _ := strconv.Atoi(configs.CommandTimeout)
+	commandTimeout = time.Duration(timeoutSeconds) * time.Second
+	debugMode = configs.Debug == "yes"
+	dryRun = configs.DryRun == "yes"
+
+	startTime := time.Now()
+	startPhase("setup")
+
+	if configs.Command == "fetch" && (configs.MatchMatrix != "" || configs.AppIdentifierTypes != "") {
+		groups, err := configs.matchGroups()
+		if err != nil {
+			fail("Failed to parse match groups, error: %s", err)
+		}
+
+		types := []string{}
+		appIDs := []string{}
+		for _, group := range groups {
+			log.Printf("%s: %s", group.Type, strings.Join(group.AppIDs, ", "))
+			types = append(types, group.Type)
+			appIDs = append(appIDs, group.AppIDs...)
+		}
+
+		configs.Type = strings.Join(types, ",")
+		configs.AppID = strings.Join(appIDs, ",")
+	}
+
+	if configs.Command == "fetch" && len(splitList(configs.AppID)) == 0 && configs.ProjectPath != "" {
+		log.Printf("App ID not specified, detecting app identifiers from project: %s", configs.ProjectPath)
+
+		appIDs, err := detectAppIDs(configs.ProjectPath, configs.Scheme)
+		if err != nil {
+			fail("Failed to detect app identifiers, error: %s", err)
+		}
+
+		log.Printf("Detected app identifiers: %s", strings.Join(appIDs, ", "))
+		configs.AppID = strings.Join(appIDs, ",")
+	}
+
+	if configs.TeamID == "" && configs.TeamName == "" && configs.ProjectPath != "" {
+		log.Printf("Team ID not specified, detecting team ID from project: %s", configs.ProjectPath)
+
+		if teamID, err := detectTeamID(configs.ProjectPath, configs.Scheme); err != nil {
+			log.Warnf("Failed to detect team ID, error: %s", err)
+		} else {
+			log.Printf("Detected team ID: %s", teamID)
+			configs.TeamID = teamID
+		}
+	}
+
+	platform := configs.Platform
+	if platform == "auto" {
+		platform = ""
+
+		if configs.ProjectPath != "" {
+			if detected, err := detectPlatform(configs.ProjectPath, configs.Scheme, configs.DeriveCatalystAppIdentifier == "yes"); err != nil {
+				log.Warnf("Failed to detect platform, using the fastlane default, error: %s", err)
+			} else {
+				log.Printf("Detected platform: %s", detected)
+				platform = detected
+			}
+		}
+	}
+
+	portalWrites := false
+	if configs.Command == "fetch" {
+		groups, err := configs.matchGroups()
+		if err != nil {
+			fail("Failed to parse match groups, error: %s", err)
+		}
+
+		registerDevices := configs.Devices != "" || configs.DevicesFile != ""
+
+		writable := writableGroups(groups, configs.Readonly == "yes")
+		if len(writable) == 0 {
+			// match doesn't regenerate the profiles in readonly mode, the registered devices would not be added
+			if registerDevices {
+				fail("Devices can only be registered with readonly disabled, match doesn't update the provisioning profiles in readonly mode")
+			}
+			if configs.ForceForNewDevices == "yes" {
+				fail("Force for new devices, only available with readonly disabled")
+			}
+		}
+
+		portalWrites = len(writable) > 0 || registerDevices
+		if portalWrites {
+			if configs.AllowCreateAssets != "yes" {
+				fail("Readonly is disabled, set allow_create_assets to yes to let match create or modify assets on the Apple Developer Portal")
+			}
+
+			if isPullRequestBuild() {
+				fail("Readonly is disabled, match can not create or modify assets on the Apple Developer Portal from a pull request build")
+			}
+
+			configs.printWriteModeBanner(writable, platform)
+		}
+	}
+
+	if configs.StorageMode == "git" && !configs.usesMatchfileStorage() && configs.GitPrivateKey == "" && configs.GitDeployKey == "" && isSSHGitURL(configs.GitURL) && !dryRun {
+		log.Printf("Checking the ssh-agent for: %s", configs.GitURL)
+
+		if err := checkSSHAgent(); err != nil {
+			fail("SSH access check failed, error: %s", err)
+		}
+	}
+
+	if configs.StorageMode == "git" && !configs.usesMatchfileStorage() && configs.AddKnownHosts == "yes" && isSSHGitURL(configs.GitURL) && !dryRun {
+		log.Printf("Adding the git storage host to the known hosts")
+
+		if err := provisionKnownHosts(configs.GitURL, splitList(configs.SSHHostFingerprints)); err != nil {
+			fail("Failed to add the known hosts, error: %s", err)
+		}
+	}
+
+	startPhase("fastlane install")
+
+	var fastlaneCmdSlice []string
+	workDir := ""
+	fastlaneVersion := ""
+	if configs.FetchEngine == "native" {
+		log.Printf("Using the native fetch engine, skipping the fastlane install")
+	} else {
+		if configs.GemfilePath == "" && configs.FastlaneVersion == "" {
+			log.Printf("No Gemfile path nor fastlane version defined, searching for a Gemfile declaring fastlane...")
+
+			gemfilePth, err := discoverGemfile(os.Getenv("BITRISE_SOURCE_DIR"))
+			if err != nil {
+				fail("Failed to search for a Gemfile, error: %s", err)
+			}
+			configs.GemfilePath = gemfilePth
+		}
+
+		fastlaneCmdSlice, workDir, fastlaneVersion = configs.setupFastlane()
+	}
+
+	registerFailureHandler(func() {
+		collectFastlaneLogs(workDir)
+	})
+
+	startPhase("credentials")
+
+	apiKeyDuration, _ := strconv.Atoi(configs.APIKeyDuration)
+	apiKeyInHouse := configs.APIKeyInHouse == "yes"
+
+	apiKeyPath := ""
+	if isRemoteURL(configs.APIKeyPath) {
+		pth, err := downloadAPIKey(configs.APIKeyPath)
+		if err != nil {
+			fail("Failed to download App Store Connect API key, error: %s", err)
+		}
+		registerSecretFileCleanup(pth, "App Store Connect API key")
+		apiKeyPath = pth
+	} else if configs.APIKeyPath != "" {
+		pth, err := prepareAPIKeyFile(configs.APIKeyPath)
+		if err != nil {
+			fail("Failed to prepare App Store Connect API key, error: %s", err)
+		}
+		apiKeyPath = pth
+	} else if configs.APIKeyContentBase64 != "" {
+		pth, err := writeAPIKeyContent(configs.APIKeyContentBase64)
+		if err != nil {
+			fail("Failed to write App Store Connect API key, error: %s", err)
+		}
+		registerSecretFileCleanup(pth, "App Store Connect API key")
+		apiKeyPath = pth
+	} else if configs.APIKeyID != "" {
+		pth, err := writeAPIKeyFromParts(configs.APIKeyID, configs.APIKeyIssuerID, configs.APIKeyP8Content, apiKeyDuration, apiKeyInHouse)
+		if err != nil {
+			fail("Failed to write App Store Connect API key, error: %s", err)
+		}
+		registerSecretFileCleanup(pth, "App Store Connect API key")
+		apiKeyPath = pth
+	} else if envKeys := apiKeyEnvKeys(); len(envKeys) > 0 {
+		// Passed to fastlane untouched, with the rest of the environment
+		log.Printf("Using the App Store Connect API key of the environment: %s", strings.Join(envKeys, ", "))
+
+		if apiKeyDuration > 0 || apiKeyInHouse {
+			log.Warnf("API key duration and in house are not applied to the API key of the environment")
+		}
+	} else if configs.UseAppleServiceConnection == "yes" && !configs.hasAppleCredentials() {
+		buildURL := os.Getenv("BITRISE_BUILD_URL")
+		buildAPIToken := os.Getenv("BITRISE_BUILD_API_TOKEN")
+
+		if buildURL != "" && buildAPIToken != "" {
+			log.Printf("Fetching the Apple service connection...")
+
+			connection, err := fetchAppleServiceConnection(buildURL, buildAPIToken)
+			if err != nil {
+				log.Warnf("Failed to fetch the Apple service connection, error: %s", err)
+			} else if connection.KeyID == "" || connection.PrivateKey == "" {
+				log.Printf("No App Store Connect API key connection is set up for the build")
+			} else {
+				pth, err := writeAPIKeyFromParts(connection.KeyID, connection.IssuerID, connection.PrivateKey, apiKeyDuration, apiKeyInHouse)
+				if err != nil {
+					fail("Failed to write App Store Connect API key, error: %s", err)
+				}
+				registerSecretFileCleanup(pth, "App Store Connect API key")
+				log.Printf("Using the App Store Connect API key of the Apple service connection: %s", connection.KeyID)
+				apiKeyPath = pth
+			}
+		}
+	}
+
+	// The key files given by the user are copied with the options folded in
+	if (configs.APIKeyPath != "" || configs.APIKeyContentBase64 != "") && (apiKeyDuration > 0 || apiKeyInHouse) {
+		pth, err := writeAPIKeyWithOptions(apiKeyPath, apiKeyDuration, apiKeyInHouse)
+		if err != nil {
+			fail("Failed to write App Store Connect API key, error: %s", err)
+		}
+		registerSecretFileCleanup(pth, "App Store Connect API key")
+		apiKeyPath = pth
+	}
+
+	googleCloudKeysFile := configs.GoogleCloudKeysFile
+	if configs.GoogleCloudKeysContent != "" {
+		pth, err := writeSecretFile("gc_keys", "gc_keys.json", []byte(configs.GoogleCloudKeysContent))
+		if err != nil {
+			fail("Failed to write Google Cloud keys file, error: %s", err)
+		}
+		registerSecretFileCleanup(pth, "Google Cloud keys file")
+		googleCloudKeysFile = pth
+	}
+
+	gitPrivateKeyPath := configs.GitPrivateKey
+	if strings.Contains(configs.GitPrivateKey, "PRIVATE KEY") {
+		content := strings.TrimSpace(configs.GitPrivateKey) + "\n"
+		pth, err := writeSecretFile("git_private_key", "id_rsa", []byte(content))
+		if err != nil {
+			fail("Failed to write git private key, error: %s", err)
+		}
+		registerSecretFileCleanup(pth, "git private key")
+		gitPrivateKeyPath = pth
+	}
+
+	// The git commands authenticate with the deploy key only, instead of the keys of the ssh-agent
+	gitSSHKeyPath := gitPrivateKeyPath
+	if configs.GitDeployKey != "" {
+		content := strings.TrimSpace(configs.GitDeployKey) + "\n"
+		pth, err := writeSecretFile("git_deploy_key", "id_deploy", []byte(content))
+		if err != nil {
+			fail("Failed to write git deploy key, error: %s", err)
+		}
+		registerSecretFileCleanup(pth, "git deploy key")
+		gitSSHKeyPath = pth
+	}
+
+	if configs.CreateKeychain == "yes" {
+		log.Printf("Creating temporary keychain...")
+
+		name, password, err := createEphemeralKeychain()
+		if err != nil {
+			fail("Failed to create temporary keychain, error: %s", err)
+		}
+
+		log.Printf("Created temporary keychain: %s", name)
+		configs.KeychainName = name
+		configs.KeychainPassword = password
+
+		// Kept for the signing steps on success, the cleanup command deletes it
+		registerFailureHandler(func() {
+			if err := deleteKeychain(name); err != nil {
+				log.Warnf("Failed to delete the temporary keychain, error: %s", err)
+			}
+		})
+
+		if err := exportOutput("MATCH_KEYCHAIN_PATH", name); err != nil {
+			fail("Failed to export outputs, error: %s", err)
+		}
+		if err := exportSecretOutput("MATCH_KEYCHAIN_PASSWORD", password); err != nil {
+			fail("Failed to export outputs, error: %s", err)
+		}
+	} else if configs.KeychainName == "" {
+		keychainPath := os.Getenv("BITRISE_KEYCHAIN_PATH")
+		keychainPassword := os.Getenv("BITRISE_KEYCHAIN_PASSWORD")
+		if keychainPath != "" && keychainPassword != "" {
+			log.Printf("Using the stack keychain: %s", keychainPath)
+			configs.KeychainName = keychainPath
+			configs.KeychainPassword = keychainPassword
+		}
+	}
+
+	if (configs.KeychainLockTimeout != "" || configs.KeychainLockOnSleep != "keep") && !dryRun {
+		keychain := configs.KeychainName
+		if keychain == "" {
+			pth, err := defaultKeychain()
+			if err != nil {
+				fail("Failed to read the default keychain, error: %s", err)
+			}
+			keychain = pth
+		}
+
+		settings, err := keychainSettings(keychain)
+		if err != nil {
+			fail("Failed to read the keychain settings, error: %s", err)
+		}
+
+		// The signing steps run with the new settings, the cleanup command restores the previous ones
+		if err := exportKeychainSettingsOutputs(keychain, settings); err != nil {
+			fail("Failed to export outputs, error: %s", err)
+		}
+
+		if configs.KeychainLockTimeout != "" {
+			settings.Timeout, _ = strconv.Atoi(configs.KeychainLockTimeout)
+		}
+		if configs.KeychainLockOnSleep != "keep" {
+			settings.LockOnSleep = configs.KeychainLockOnSleep == "yes"
+		}
+
+		log.Printf("Setting the keychain lock timeout to %ds, lock on sleep: %t", settings.Timeout, settings.LockOnSleep)
+		if err := setKeychainSettings(keychain, settings); err != nil {
+			fail("Failed to set the keychain settings, error: %s", err)
+		}
+	}
+
+	// The cleanup command only removes the identities the fetch imports, not the ones already installed
+	var preexistingIdentities map[string]bool
+	if configs.Command == "fetch" && !dryRun {
+		sha1s, err := keychainCertificateSHA1s(configs.KeychainName)
+		if err != nil {
+			log.Warnf("Failed to list the installed certificates, the cleanup command will keep the imported identities, error: %s", err)
+		}
+		preexistingIdentities = sha1s
+	}
+
+	elapsed := time.Since(startTime)
+
+	log.Printf("Setup took %f seconds to complete", elapsed.Seconds())
+
+	//
+	// Main
+	fmt.Println()
+	log.Infof("Running Match")
+
+	matchStartTime := time.Now()
+	startPhase("match")
+
+	options := []string{}
+	if configs.Options != "" {
+		opts, err := shellquote.Split(configs.Options)
+		if err != nil {
+			fail("Failed to split options (%s), error: %s", configs.Options, err)
+		}
+		options = opts
+	}
+
+	envs := []string{}
+
+	if configs.DecryptPassword != "" {
+		envs = append(envs, fmt.Sprintf("MATCH_PASSWORD=%s", configs.DecryptPassword))
+	}
+
+	runDir := ""
+	if configs.WorkingDirectory != "" {
+		dir, err := pathutil.AbsPath(configs.WorkingDirectory)
+		if err != nil {
+			fail("Failed to expand working directory (%s), error: %s", configs.WorkingDirectory, err)
+		}
+
+		log.Printf("Running match from the working directory: %s", dir)
+		runDir = dir
+	} else if configs.MatchfilePath != "" {
+		matchfileDir, err := matchfileWorkDir(configs.MatchfilePath)
+		if err != nil {
+			fail("Failed to expand Matchfile path (%s), error: %s", configs.MatchfilePath, err)
+		}
+
+		log.Printf("Running match from the Matchfile directory: %s", matchfileDir)
+		runDir = matchfileDir
+	}
+
+	if runDir != "" {
+		if workDir != "" {
+			// bundler has to find the Gemfile outside of its directory
+			gemfilePath, err := pathutil.AbsPath(configs.GemfilePath)
+			if err != nil {
+				fail("Failed to expand Gemfile path (%s), error: %s", configs.GemfilePath, err)
+			}
+			envs = append(envs, fmt.Sprintf("BUNDLE_GEMFILE=%s", gemfilePath))
+		}
+
+		workDir = runDir
+	}
+
+	if configs.StorageMode == "s3" && (configs.S3Endpoint != "" || configs.S3ForcePathStyle == "yes") {
+		rubyOpt, err := configs.writeS3ConfigHook()
+		if err != nil {
+			fail("Failed to write the S3 client configuration, error: %s", err)
+		}
+		envs = append(envs, fmt.Sprintf("RUBYOPT=%s", rubyOpt))
+	}
+
+	if configs.Verbose == "yes" {
+		envs = append(envs, "FASTLANE_VERBOSE=true")
+	}
+
+	if gitPrivateKeyPath != "" {
+		envs = append(envs, fmt.Sprintf("MATCH_GIT_PRIVATE_KEY=%s", gitPrivateKeyPath))
+	}
+
+	if configs.GitDeployKey != "" {
+		envs = append(envs, fmt.Sprintf("GIT_SSH_COMMAND=%s", gitSSHCommand(gitSSHKeyPath)))
+	}
+
+	if configs.AppleID != "" {
+		envs = append(envs, fmt.Sprintf("FASTLANE_USER=%s", configs.AppleID))
+	}
+
+	if configs.TeamName != "" {
+		envs = append(envs, fmt.Sprintf("FASTLANE_TEAM_NAME=%s", configs.TeamName))
+	}
+
+	if configs.AppleIDPassword != "" {
+		envs = append(envs, fmt.Sprintf("FASTLANE_PASSWORD=%s", configs.AppleIDPassword))
+	}
+
+	if configs.FastlaneSession != "" {
+		envs = append(envs, fmt.Sprintf("FASTLANE_SESSION=%s", configs.FastlaneSession))
+	}
+
+	gitBasicAuthorization := configs.GitBasicAuthorization
+	if strings.Contains(gitBasicAuthorization, ":") {
+		gitBasicAuthorization = base64.StdEncoding.EncodeToString([]byte(gitBasicAuthorization))
+	}
+
+	outputPath := ""
+	if configs.OutputPath != "" {
+		pth, err := pathutil.AbsPath(configs.OutputPath)
+		if err != nil {
+			fail("Failed to expand output path (%s), error: %s", configs.OutputPath, err)
+		}
+		if err := pathutil.EnsureDirExist(pth); err != nil {
+			fail("Failed to create output path (%s), error: %s", pth, err)
+		}
+		outputPath = pth
+	} else if configs.Command == "fetch" {
+		// match copies the fetched assets to the output path,
+		// a temporary one is used to inspect them after the run
+		pth, err := pathutil.NormalizedOSTempDirPath("match_output")
+		if err != nil {
+			fail("Failed to create temporary output path, error: %s", err)
+		}
+		registerCleanup(func() {
+			if err := command.RemoveDir(pth); err != nil {
+				log.Warnf("Failed to remove temporary output path, error: %s", err)
+			}
+		})
+		outputPath = pth
+	}
+
+	secrets := []string{
+		configs.KeychainPassword,
+		configs.S3AccessKey,
+		configs.S3SecretAccessKey,
+		configs.GitBasicAuthorization,
+		gitBasicAuthorization,
+		configs.GitlabJobToken,
+		configs.GitlabPrivateToken,
+		configs.DecryptPassword,
+		configs.NewDecryptPassword,
+		configs.AppleIDPassword,
+		configs.FastlaneSession,
+	}
+
+	// The first branch is used if the preflight doesn't select one
+	gitBranches := splitList(configs.GitBranch)
+	if len(gitBranches) > 1 {
+		configs.GitBranch = gitBranches[0]
+	}
+
+	storageCommit := ""
+	storageBranchExists := true
+
+	// The storage is cloned once, for the decrypt password check, the team ID detection and the native fetch
+	var storageClone *nativeStorageModel
+	cloneStorage := func() (nativeStorageModel, error) {
+		if storageClone == nil {
+			storage, err := cloneNativeStorage(configs.GitURL, configs.storageBranch(), gitSSHKeyPath, gitBasicAuthorization, configs.DecryptPassword)
+			if err != nil {
+				return nativeStorageModel{}, err
+			}
+			storageClone = &storage
+		}
+		return *storageClone, nil
+	}
+
+	if configs.StorageMode == "git" && !configs.usesMatchfileStorage() && !dryRun {
+		log.Printf("Checking access to the git storage: %s", configs.GitURL)
+
+		if configs.GitRef != "" {
+			commit, err := resolveGitRef(configs.GitURL, configs.GitRef, gitSSHKeyPath, gitBasicAuthorization)
+			if err != nil {
+				fail("Git storage check failed, error: %s", err)
+			}
+
+			log.Printf("Git ref %s resolved to commit: %s", configs.GitRef, commit)
+			storageCommit = commit
+		} else {
+			branch, exists, err := checkGitAccess(configs.GitURL, gitBranches, gitSSHKeyPath, gitBasicAuthorization, configs.Readonly == "yes")
+			if err != nil {
+				fail("Git storage check failed, error: %s", err)
+			}
+			storageBranchExists = exists
+
+			if len(gitBranches) > 1 {
+				log.Printf("Using git branch: %s", branch)
+				configs.GitBranch = branch
+			}
+		}
+
+		// The native engine decrypts the storage right away, a branch match creates has nothing to decrypt yet
+		if configs.DecryptPassword != "" && configs.FetchEngine != "native" && storageBranchExists {
+			log.Printf("Checking the decrypt password")
+
+			storage, err := cloneStorage()
+			if err == nil {
+				err = checkDecryptPassword(storage)
+			}
+
+			if err == errBadDecrypt {
+				msg := "the decrypt password does not match the passphrase the storage was encrypted with"
+				exportResult("failed_decrypt", msg)
+				log.Errorf("Decrypt password check failed, error: %s", msg)
+				exit(decryptExitCode)
+			} else if err != nil {
+				log.Warnf("Skipping the decrypt password check, error: %s", err)
+			}
+		}
+
+		// match logs in to the Apple Developer Portal to create assets, the team is resolved before it could prompt for one
+		if configs.TeamID == "" && configs.TeamName == "" && portalWrites && configs.DecryptPassword != "" && storageBranchExists {
+			log.Printf("Team ID not specified, detecting team ID from the stored certificates")
+
+			storage, err := cloneStorage()
+			if err != nil {
+				log.Warnf("Failed to detect team ID, error: %s", err)
+			} else if teamID, err := storage.teamID(); err != nil {
+				log.Warnf("Failed to detect team ID, error: %s", err)
+			} else if teamID != "" {
+				log.Printf("Detected team ID: %s", teamID)
+				configs.TeamID = teamID
+			}
+		}
+	}
+
+	// An API key belongs to a single team, the team of an Apple ID login may also come from the Matchfile or the environment
+	teamSet := configs.TeamID != "" || configs.TeamName != "" || configs.MatchfilePath != "" || os.Getenv("FASTLANE_TEAM_ID") != ""
+	if !teamSet && portalWrites && apiKeyPath == "" && !dryRun {
+		fail("Team ID could not be detected, set team_id or team_name: fastlane would prompt for the team if the Apple ID is in multiple teams")
+	}
+
+	if configs.FastlaneSession != "" && !dryRun {
+		log.Printf("Checking the fastlane session")
+
+		if err := checkFastlaneSession(configs.FastlaneSession); err != nil {
+			if _, ok := err.(errInvalidSession); ok {
+				exportResult("failed_auth", err.Error())
+				log.Errorf("Fastlane session check failed, error: %s", err)
+				exit(authExitCode)
+			}
+			log.Warnf("Failed to check the fastlane session, error: %s", err)
+		}
+	}
+
+	if debugMode {
+		logDebugInfo(fastlaneVersion, envs, secrets)
+	}
+
+	switch configs.Command {
+	case "nuke":
+		fmt.Println()
+		log.Infof("Running Match nuke for type: %s", configs.NukeType)
+
+		args := []string{
+			"match",
+			"nuke",
+			configs.NukeType,
+			"--skip_confirmation",
+		}
+
+		args = append(args, configs.authArgs(apiKeyPath)...)
+		args = append(args, configs.storageArgs(gitBasicAuthorization, googleCloudKeysFile)...)
+		args = append(args, options...)
+
+		if out, err := runFastlane(fastlaneCmdSlice, workDir, args, envs, secrets); err != nil {
+			failFastlane(out, err, "Nuke failed for type: %s", configs.NukeType)
+		}
+	case "import":
+		fmt.Println()
+		log.Infof("Running Match import for type: %s", configs.Type)
+
+		args := []string{
+			"match",
+			"import",
+			"--type", configs.Type,
+		}
+
+		args = append(args, configs.importArgs()...)
+		args = append(args, configs.authArgs(apiKeyPath)...)
+		args = append(args, configs.storageArgs(gitBasicAuthorization, googleCloudKeysFile)...)
+		args = append(args, options...)
+
+		if out, err := runFastlane(fastlaneCmdSlice, workDir, args, envs, secrets); err != nil {
+			failFastlane(out, err, "Import failed for type: %s", configs.Type)
+		}
+	case "change_password":
+		fmt.Println()
+		log.Infof("Running Match change_password")
+
+		args := []string{
+			"match",
+			"change_password",
+		}
+
+		args = append(args, configs.storageArgs(gitBasicAuthorization, googleCloudKeysFile)...)
+		args = append(args, options...)
+
+		changePasswordEnvs := append(append([]string{}, envs...), fmt.Sprintf("MATCH_NEW_PASSWORD=%s", configs.NewDecryptPassword))
+
+		if out, err := runFastlane(fastlaneCmdSlice, workDir, args, changePasswordEnvs, secrets); err != nil {
+			failFastlane(out, err, "Change password failed")
+		}
+	case "decrypt":
+		fmt.Println()
+		log.Infof("Running Match decrypt")
+
+		args := []string{
+			"match",
+			"decrypt",
+		}
+
+		args = append(args, configs.storageArgs(gitBasicAuthorization, googleCloudKeysFile)...)
+		args = append(args, options...)
+
+		out, err := runFastlane(fastlaneCmdSlice, workDir, args, envs, secrets)
+		if err != nil {
+			failFastlane(out, err, "Decrypt failed")
+		}
+
+		if dryRun {
+			break
+		}
+
+		fmt.Println()
+		log.Infof("Exporting decrypted assets to: %s", outputPath)
+
+		if err := exportDecryptedAssets(out, outputPath); err != nil {
+			fail("Failed to export decrypted assets, error: %s", err)
+		}
+	default:
+		forceForNewDevices := configs.ForceForNewDevices == "yes"
+
+		if configs.DevicesFile != "" || configs.Devices != "" {
+			fmt.Println()
+			log.Infof("Registering devices")
+
+			devicesFile, err := configs.prepareDevicesFile()
+			if err != nil {
+				fail("Failed to prepare devices file, error: %s", err)
+			}
+
+			args := []string{
+				"run",
+				"register_devices",
+			}
+			args = append(args, registerDevicesArgs(devicesFile, apiKeyPath, configs.AppleID, configs.TeamID, configs.TeamName)...)
+
+			if out, err := runFastlane(fastlaneCmdSlice, workDir, args, envs, secrets); err != nil {
+				failFastlane(out, err, "Device registration failed")
+			}
+
+			forceForNewDevices = true
+		}
+
+		groups, err := configs.matchGroups()
+		if err != nil {
+			fail("Failed to parse match groups, error: %s", err)
+		}
+
+		if configs.FetchEngine == "native" && dryRun {
+			for _, group := range groups {
+				log.Printf("Would install type: %s, app identifiers: %s", group.Type, strings.Join(group.AppIDs, ", "))
+			}
+			log.Warnf("Dry run, skipping the native fetch")
+			break
+		}
+
+		if configs.FetchEngine == "native" {
+			storage, err := cloneStorage()
+			if err != nil {
+				fail("Failed to clone the storage, error: %s", err)
+			}
+
+			if storageCommit != "" {
+				if err := verifyGitHead(storage.Dir, storageCommit); err != nil {
+					fail("Failed to verify the storage clone, error: %s", err)
+				}
+			}
+
+			for _, group := range groups {
+				fmt.Println()
+				log.Infof("Installing type: %s", group.Type)
+
+				groupPlatform := platform
+				if group.Platform != "" {
+					groupPlatform = group.Platform
+				}
+
+				if err := nativeFetch(storage, group, groupPlatform, outputPath, configs.KeychainName, configs.KeychainPassword, configs.SkipSetPartitionList != "yes", configs.SkipProvisioningProfiles == "yes"); err != nil {
+					failFastlane(err.Error(), err, "Installation failed for type: %s", group.Type)
+				}
+			}
+			break
+		}
+
+		retryCount, _ := strconv.Atoi(configs.RetryCount)
+		retryWaitSeconds, _ := strconv.Atoi(configs.RetryWaitSeconds)
+		retryWait := time.Duration(retryWaitSeconds) * time.Second
+
+		for _, group := range groups {
+			matchType := group.Type
+
+			fmt.Println()
+			log.Infof("Running Match for type: %s", matchType)
+			log.Printf("App identifiers: %s", strings.Join(group.AppIDs, ", "))
+
+			readonly := configs.Readonly == "yes"
+			if group.Readonly != nil {
+				readonly = *group.Readonly
+			}
+
+			groupPlatform := platform
+			if group.Platform != "" {
+				groupPlatform = group.Platform
+			}
+
+			args := []string{
+				"match",
+				matchType,
+			}
+
+			if readonly {
+				args = append(args, "--readonly")
+			}
+
+			if configs.Force == "yes" {
+				args = append(args, "--force")
+			}
+
+			if forceForNewDevices && !readonly && supportsForceForNewDevices(matchType) {
+				args = append(args, "--force_for_new_devices")
+			}
+
+			args = append(args, configs.authArgs(apiKeyPath)...)
+
+			if configs.KeychainName != "" {
+				args = append(args, "--keychain_name", configs.KeychainName)
+				args = append(args, "--keychain_password", configs.KeychainPassword)
+			}
+
+			if configs.SkipSetPartitionList == "yes" {
+				args = append(args, "--skip_set_partition_list")
+			}
+
+			switch configs.GenerateAppleCerts {
+			case "yes":
+				args = append(args, "--generate_apple_certs", "true")
+			case "no":
+				args = append(args, "--generate_apple_certs", "false")
+			}
+
+			args = append(args, configs.storageArgs(gitBasicAuthorization, googleCloudKeysFile)...)
+
+			if len(group.AppIDs) > 0 {
+				args = append(args, "--app_identifier", strings.Join(group.AppIDs, ","))
+			}
+
+			if configs.SkipProvisioningProfiles == "yes" {
+				args = append(args, "--skip_provisioning_profiles")
+			}
+
+			if configs.SkipCertificateMatching == "yes" {
+				args = append(args, "--skip_certificate_matching")
+			}
+
+			if configs.TemplateName != "" {
+				args = append(args, "--template_name", configs.TemplateName)
+			}
+
+			if configs.ProfileName != "" {
+				args = append(args, "--profile_name", configs.ProfileName)
+			}
+
+			if outputPath != "" {
+				args = append(args, "--output_path", outputPath)
+			}
+
+			if configs.SkipDocs == "yes" {
+				args = append(args, "--skip_docs")
+			}
+
+			if configs.Verbose == "yes" {
+				args = append(args, "--verbose")
+			}
+
+			if configs.IncludeMacInProfiles == "yes" {
+				args = append(args, "--include_mac_in_profiles")
+			}
+
+			if groupPlatform != "" {
+				args = append(args, "--platform", groupPlatform)
+			}
+
+			if additionalCertTypes := splitList(configs.AdditionalCertTypes); len(additionalCertTypes) > 0 {
+				args = append(args, "--additional_cert_types", strings.Join(additionalCertTypes, ","))
+			}
+
+			if configs.FailOnNameTaken == "yes" {
+				args = append(args, "--fail_on_name_taken")
+			}
+
+			if configs.DeriveCatalystAppIdentifier == "yes" {
+				args = append(args, "--derive_catalyst_app_identifier")
+			}
+
+			args = append(args, options...)
+			args = append(args, group.Options...)
+
+			if out, err := runFastlaneWithRetry(retryCount, retryWait, fastlaneCmdSlice, workDir, args, envs, secrets); err != nil {
+				failFastlane(out, err, "Download or installation failed for type: %s", matchType)
+			}
+		}
+
+		// match clones the tag itself, it must still point to the verified commit
+		if storageCommit != "" {
+			commit, err := resolveGitRef(configs.GitURL, configs.GitRef, gitSSHKeyPath, gitBasicAuthorization)
+			if err != nil {
+				fail("Failed to verify the git ref, error: %s", err)
+			}
+			if commit != storageCommit {
+				fail("Git ref %s moved from %s to %s during the run", configs.GitRef, storageCommit, commit)
+			}
+		}
+	}
+
+	matchElapsed := time.Since(matchStartTime)
+
+	if dryRun {
+		printPhaseSummary("succeeded")
+
+		fmt.Println()
+		log.Donef("Dry run finished, nothing was fetched or changed")
+		return
+	}
+
+	startPhase("export")
+
+	if configs.Command == "fetch" {
+		profiles, err := findProfiles(outputPath)
+		if err != nil {
+			fail("Failed to parse the fetched provisioning profiles, error: %s", err)
+		}
+
+		// Older fastlane versions install the profiles only for Xcode 15 and earlier.
+		if configs.FetchEngine != "native" {
+			for _, profile := range profiles {
+				if _, err := installProfile(profile.Path); err != nil {
+					fail("Failed to install provisioning profile %s, error: %s", profile.Path, err)
+				}
+			}
+		}
+
+		if len(profiles) > 0 {
+			fmt.Println()
+			log.Infof("Installed provisioning profiles")
+			printProfileTable(profiles)
+		}
+
+		certificates, err := findCertificates(outputPath)
+		if err != nil {
+			fail("Failed to parse the fetched certificates, error: %s", err)
+		}
+
+		if configs.SkipSetPartitionList != "yes" && configs.KeychainName != "" && configs.KeychainPassword != "" && len(certificates) > 0 {
+			sha1s := []string{}
+			for _, certificate := range certificates {
+				sha1s = append(sha1s, certificate.SHA1)
+			}
+
+			fmt.Println()
+			log.Printf("Verifying the installed codesigning identities")
+			if err := verifyCodesignIdentities(configs.KeychainName, sha1s); err != nil {
+				log.Warnf("Codesigning identity check failed, codesign may prompt for the keychain password, error: %s", err)
+			}
+		}
+
+		if configs.ExpiryFailThresholdDays != "" {
+			days, _ := strconv.Atoi(configs.ExpiryFailThresholdDays)
+
+			if expiring := expiringProfiles(profiles, days, time.Now()); len(expiring) > 0 {
+				fmt.Println()
+				for _, profile := range expiring {
+					log.Warnf("Provisioning profile %s (%s) expires at: %s", profile.Name, profile.UUID, profile.ExpirationDate.Format(time.RFC1123))
+				}
+
+				if configs.ExpiryThresholdAction == "fail" {
+					fail("%d provisioning profile(s) expire within %d days", len(expiring), days)
+				}
+			}
+		}
+
+		if configs.ProjectPath != "" && configs.EntitlementsCheck != "off" && configs.SkipProvisioningProfiles != "yes" {
+			issues, err := checkEntitlements(configs.ProjectPath, configs.Scheme, profiles)
+			if err != nil {
+				log.Warnf("Failed to check the project entitlements, error: %s", err)
+			} else if len(issues) > 0 {
+				fmt.Println()
+				for _, issue := range issues {
+					log.Warnf("%s", issue)
+				}
+
+				if configs.EntitlementsCheck == "fail" {
+					fail("%d provisioning profile(s) don't match the project", len(issues))
+				}
+			}
+		}
+
+		if configs.CertificateExpiryWarningDays != "" {
+			days, _ := strconv.Atoi(configs.CertificateExpiryWarningDays)
+
+			expiring := expiringCertificates(certificates, days, time.Now())
+			expiringNames := []string{}
+			for _, certificate := range expiring {
+				expiringNames = append(expiringNames, certificate.CommonName)
+			}
+
+			if len(expiring) > 0 {
+				fmt.Println()
+				log.Warnf("%d certificate(s) expire within %d days:", len(expiring), days)
+				for _, certificate := range expiring {
+					log.Warnf("- %s (%s) expires at: %s", certificate.CommonName, certificate.SHA1, certificate.ExpirationDate.Format(time.RFC1123))
+				}
+			}
+
+			if err := exportOutput("MATCH_EXPIRING_CERTS", strings.Join(expiringNames, outputListSeparator)); err != nil {
+				fail("Failed to export outputs, error: %s", err)
+			}
+		}
+
+		fmt.Println()
+		log.Infof("Exporting outputs")
+
+		if err := exportProfileOutputs(profiles); err != nil {
+			fail("Failed to export outputs, error: %s", err)
+		}
+
+		if err := exportCertificateOutputs(certificates); err != nil {
+			fail("Failed to export outputs, error: %s", err)
+		}
+
+		importedSHA1s := []string{}
+		for _, certificate := range certificates {
+			if preexistingIdentities != nil && !preexistingIdentities[certificate.SHA1] {
+				importedSHA1s = append(importedSHA1s, certificate.SHA1)
+			}
+		}
+		if err := exportOutput("MATCH_IMPORTED_IDENTITY_SHA1S", strings.Join(importedSHA1s, outputListSeparator)); err != nil {
+			fail("Failed to export outputs, error: %s", err)
+		}
+
+		if err := exportSigningOutputs(splitList(configs.Type), splitList(configs.AppID), configs.TeamID, profiles); err != nil {
+			fail("Failed to export outputs, error: %s", err)
+		}
+
+		installedProfilePaths := []string{}
+		installedProfileCopyPaths := []string{}
+		for _, profile := range profiles {
+			installedProfilePaths = append(installedProfilePaths, installedProfilePath(profile))
+			installedProfileCopyPaths = append(installedProfileCopyPaths, installedProfileCopies(profile)...)
+		}
+
+		if storageCommit != "" {
+			if err := exportOutput("MATCH_STORAGE_COMMIT", storageCommit); err != nil {
+				fail("Failed to export outputs, error: %s", err)
+			}
+		}
+
+		if err := exportOutput("MATCH_PROFILE_PATHS", strings.Join(installedProfilePaths, outputListSeparator)); err != nil {
+			fail("Failed to export outputs, error: %s", err)
+		}
+
+		if err := exportOutput("MATCH_INSTALLED_PROFILE_PATHS", strings.Join(installedProfileCopyPaths, outputListSeparator)); err != nil {
+			fail("Failed to export outputs, error: %s", err)
+		}
+
+		if configs.CopyProfilesToDeployDir == "yes" {
+			if deployDir := os.Getenv("BITRISE_DEPLOY_DIR"); deployDir == "" {
+				log.Warnf("BITRISE_DEPLOY_DIR is not set, skipping the copy of the installed provisioning profiles")
+			} else {
+				for _, pth := range installedProfilePaths {
+					if err := command.CopyFile(pth, filepath.Join(deployDir, filepath.Base(pth))); err != nil {
+						fail("Failed to copy provisioning profile (%s) to the deploy dir, error: %s", pth, err)
+					}
+				}
+			}
+		}
+
+		if configs.GenerateExportOptions == "yes" {
+			exportOptionsDir := os.Getenv("BITRISE_DEPLOY_DIR")
+			if exportOptionsDir == "" {
+				pth, err := pathutil.NormalizedOSTempDirPath("export_options")
+				if err != nil {
+					fail("Failed to create temporary directory, error: %s", err)
+				}
+				exportOptionsDir = pth
+			}
+
+			pths, err := writeExportOptions(exportOptionsDir, splitList(configs.Type), configs.TeamID, profiles)
+			if err != nil {
+				fail("Failed to generate exportOptions.plist, error: %s", err)
+			}
+
+			if err := exportOutput("MATCH_EXPORT_OPTIONS_PATH", strings.Join(pths, outputListSeparator)); err != nil {
+				fail("Failed to export outputs, error: %s", err)
+			}
+		}
+
+		if configs.GenerateXcconfig == "yes" {
+			xcconfigDir := os.Getenv("BITRISE_DEPLOY_DIR")
+			if xcconfigDir == "" {
+				pth, err := pathutil.NormalizedOSTempDirPath("xcconfig")
+				if err != nil {
+					fail("Failed to create temporary directory, error: %s", err)
+				}
+				xcconfigDir = pth
+			}
+
+			pths, err := writeXcconfigs(xcconfigDir, splitList(configs.Type), configs.TeamID, profiles, certificates)
+			if err != nil {
+				fail("Failed to generate xcconfig, error: %s", err)
+			}
+
+			if err := exportOutput("MATCH_XCCONFIG_PATH", strings.Join(pths, outputListSeparator)); err != nil {
+				fail("Failed to export outputs, error: %s", err)
+			}
+		}
+
+		if configs.GenerateSummary == "yes" {
+			if deployDir := os.Getenv("BITRISE_DEPLOY_DIR"); deployDir == "" {
+				log.Warnf("BITRISE_DEPLOY_DIR is not set, skipping the run summary")
+			} else {
+				summary := newSummaryModel(fastlaneVersion, splitList(configs.Type), splitList(configs.AppID), profiles, certificates)
+				summary.Durations["setup"] = elapsed.Seconds()
+				summary.Durations["match"] = matchElapsed.Seconds()
+
+				pth := filepath.Join(deployDir, "match_summary.json")
+				if err := writeSummary(pth, summary); err != nil {
+					fail("Failed to write run summary, error: %s", err)
+				}
+
+				if err := exportOutput("MATCH_SUMMARY_PATH", pth); err != nil {
+					fail("Failed to export outputs, error: %s", err)
+				}
+			}
+		}
+	}
+
+	if configs.Command == "fetch" && configs.GenerateMatchfile == "yes" {
+		if deployDir := os.Getenv("BITRISE_DEPLOY_DIR"); deployDir == "" {
+			log.Warnf("BITRISE_DEPLOY_DIR is not set, skipping the Matchfile generation")
+		} else {
+			pth := filepath.Join(deployDir, "Matchfile")
+			if err := fileutil.WriteStringToFile(pth, configs.matchfileContent(platform)); err != nil {
+				fail("Failed to write Matchfile, error: %s", err)
+			}
+
+			if err := exportOutput("MATCH_MATCHFILE_PATH", pth); err != nil {
+				fail("Failed to export outputs, error: %s", err)
+			}
+		}
+	}
+
+	if configs.ExportToDeployDir == "yes" {
+		deployDir := os.Getenv("BITRISE_DEPLOY_DIR")
+		if deployDir == "" {
+			log.Warnf("BITRISE_DEPLOY_DIR is not set, skipping the export of the fetched assets")
+		} else {
+			fmt.Println()
+			log.Infof("Exporting fetched assets to: %s", deployDir)
+
+			if err := command.CopyDir(outputPath, deployDir, true); err != nil {
+				fail("Failed to export fetched assets, error: %s", err)
+			}
+		}
+	}
+
+	exportResult("success", "")
+
+	printPhaseSummary("succeeded")
+
+	fmt.Println()
 	log.Donef("Success")
 }
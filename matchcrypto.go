@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"strings"
+)
+
+// The file formats match encrypts the storage with: the legacy OpenSSL
+// compatible AES-256-CBC one and the AES-256-GCM one of newer fastlane versions.
+const (
+	matchV1Prefix = "Salted__"
+	matchV2Prefix = "match_encrypted_v2__"
+)
+
+var errBadDecrypt = errors.New("bad decrypt, wrong password")
+
+// evpBytesToKey is OpenSSL's EVP_BytesToKey key derivation with one iteration.
+func evpBytesToKey(newHash func() hash.Hash, password, salt []byte, keyLen, ivLen int) ([]byte, []byte) {
+	derived := []byte{}
+	prev := []byte{}
+	for len(derived) < keyLen+ivLen {
+		h := newHash()
+		h.Write(prev)
+		h.Write(password)
+		h.Write(salt)
+		prev = h.Sum(nil)
+		derived = append(derived, prev...)
+	}
+	return derived[:keyLen], derived[keyLen : keyLen+ivLen]
+}
+
+// pbkdf2 is the PBKDF2 key derivation (RFC 8018) with the given HMAC hash.
+func pbkdf2(newHash func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(newHash, password)
+	derived := []byte{}
+
+	for block := uint32(1); len(derived) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		counter := make([]byte, 4)
+		binary.BigEndian.PutUint32(counter, block)
+		prf.Write(counter)
+		u := prf.Sum(nil)
+
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(nil)
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		derived = append(derived, t...)
+	}
+
+	return derived[:keyLen]
+}
+
+func decryptMatchV1(data, password []byte, newHash func() hash.Hash) ([]byte, error) {
+	if len(data) < 16 || (len(data)-16)%aes.BlockSize != 0 || len(data) == 16 {
+		return nil, errors.New("invalid encrypted data length")
+	}
+
+	salt := data[8:16]
+	encrypted := data[16:]
+
+	key, iv := evpBytesToKey(newHash, password, salt, 32, aes.BlockSize)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, encrypted)
+
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errBadDecrypt
+	}
+	if !bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errBadDecrypt
+	}
+
+	return decrypted[:len(decrypted)-padding], nil
+}
+
+func decryptMatchV2(data, password []byte) ([]byte, error) {
+	headerLen := len(matchV2Prefix) + 8 + 16
+	if len(data) < headerLen {
+		return nil, errors.New("invalid encrypted data length")
+	}
+
+	salt := data[len(matchV2Prefix) : len(matchV2Prefix)+8]
+	authTag := data[len(matchV2Prefix)+8 : headerLen]
+	encrypted := data[headerLen:]
+
+	derived := pbkdf2(sha256.New, password, salt, 10000, 32+12+24)
+	key, nonce, authData := derived[:32], derived[32:44], derived[44:]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted, err := gcm.Open(nil, nonce, append(append([]byte{}, encrypted...), authTag...), authData)
+	if err != nil {
+		return nil, errBadDecrypt
+	}
+	return decrypted, nil
+}
+
+// decryptMatchFile decrypts the base64 encoded content of a file of the match storage.
+// The legacy format is tried with both of the hash algorithms match used for the key derivation.
+func decryptMatchFile(content, password string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Replace(strings.TrimSpace(content), "\n", "", -1))
+	if err != nil {
+		return nil, errors.New("not a match encrypted file")
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte(matchV2Prefix)):
+		return decryptMatchV2(data, []byte(password))
+	case bytes.HasPrefix(data, []byte(matchV1Prefix)):
+		decrypted, err := decryptMatchV1(data, []byte(password), md5.New)
+		if err == errBadDecrypt {
+			decrypted, err = decryptMatchV1(data, []byte(password), sha256.New)
+		}
+		return decrypted, err
+	}
+
+	return nil, errors.New("not a match encrypted file")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// The v1 fixtures are encrypted by `openssl enc -aes-256-cbc -md <md5|sha256> -S 0102030405060708`,
+// as match encrypts its storage. The v2 fixture is the match_encrypted_v2__ layout of fastlane's
+// MatchDataEncryption: salt, GCM auth tag and data, keyed by PBKDF2-HMAC-SHA256 with 10000 iterations.
+const (
+	testMatchPassword  = "correct-horse"
+	testMatchPlaintext = "match test certificate"
+	testMatchV1MD5     = "U2FsdGVkX18BAgMEBQYHCLsDIlO4dB8mOYo3pB5pa4a5ZHY6zLPtPzjJAEnFV6XD"
+	testMatchV1SHA256  = "U2FsdGVkX18BAgMEBQYHCINUrGx0+81oWSwfMTjptKl82ikUuaZVbeK/t41If3Aq"
+	testMatchV2        = "bWF0Y2hfZW5jcnlwdGVkX3YyX18IBwYFBAMCAVHnFLNYsEFqgSo3z14NbsUvjQ2jR0g3HLyfatWVXnEc2W01WJ2u"
+)
+
+func TestDecryptMatchFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "v1 md5", content: testMatchV1MD5},
+		{name: "v1 sha256", content: testMatchV1SHA256},
+		{name: "v1 wrapped", content: testMatchV1MD5[:40] + "\n" + testMatchV1MD5[40:] + "\n"},
+		{name: "v2", content: testMatchV2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decrypted, err := decryptMatchFile(tt.content, testMatchPassword)
+			if err != nil {
+				t.Fatalf("decryptMatchFile() error = %s", err)
+			}
+			if string(decrypted) != testMatchPlaintext {
+				t.Errorf("decryptMatchFile() = %q, want %q", decrypted, testMatchPlaintext)
+			}
+		})
+	}
+}
+
+// A wrong password passes the CBC padding check of the v1 format about once in a hundred,
+// the wrong password of the test is one that fails with both of the hash algorithms.
+func TestDecryptMatchFileWrongPassword(t *testing.T) {
+	for _, content := range []string{testMatchV1MD5, testMatchV1SHA256, testMatchV2} {
+		if _, err := decryptMatchFile(content, "wrong-password"); err != errBadDecrypt {
+			t.Errorf("decryptMatchFile(%s) error = %v, want %v", content, err, errBadDecrypt)
+		}
+	}
+}
+
+func TestDecryptMatchFileInvalid(t *testing.T) {
+	for _, content := range []string{"", "not base64!", "aGVsbG8gd29ybGQ=", "U2FsdGVkX18BAgMEBQYHCA=="} {
+		if _, err := decryptMatchFile(content, testMatchPassword); err == nil || err == errBadDecrypt {
+			t.Errorf("decryptMatchFile(%q) error = %v, want a format error", content, err)
+		}
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		password   string
+		salt       []byte
+		iterations int
+		keyLen     int
+		want       string
+	}{
+		// RFC 7914 section 11
+		{password: "passwd", salt: []byte("salt"), iterations: 1, keyLen: 64, want: "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{password: "password", salt: []byte("salt"), iterations: 2, keyLen: 32, want: "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{password: testMatchPassword, salt: []byte{8, 7, 6, 5, 4, 3, 2, 1}, iterations: 10000, keyLen: 32 + 12 + 24, want: "38379b95c108e67563907e5af7be3fb94751531489d0650242b3b20aab795f6fc239031814679253e65160c4274c67cf1a973f76e9cdb5c3fab6d17c189785d3d1e03d52"},
+	}
+
+	for _, tt := range tests {
+		if got := hex.EncodeToString(pbkdf2(sha256.New, []byte(tt.password), tt.salt, tt.iterations, tt.keyLen)); got != tt.want {
+			t.Errorf("pbkdf2(%s, %d) = %s, want %s", tt.password, tt.iterations, got, tt.want)
+		}
+	}
+}
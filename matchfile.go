@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// hasStorageInputs reports whether the storage of the selected mode is configured in the step inputs.
+func (configs ConfigsModel) hasStorageInputs() bool {
+	switch configs.StorageMode {
+	case "git":
+		return configs.GitURL != ""
+	case "s3":
+		return configs.S3Bucket != ""
+	case "google_cloud":
+		return configs.GoogleCloudBucketName != ""
+	case "gitlab_secure_files":
+		return configs.GitlabProject != ""
+	}
+	return false
+}
+
+// usesMatchfileStorage reports whether the storage is left to the user's Matchfile.
+func (configs ConfigsModel) usesMatchfileStorage() bool {
+	return configs.MatchfilePath != "" && !configs.hasStorageInputs()
+}
+
+// matchfileWorkDir returns the directory match has to run from to pick up the Matchfile,
+// fastlane looks for it in the ./fastlane directory.
+func matchfileWorkDir(matchfilePath string) (string, error) {
+	pth, err := filepath.Abs(matchfilePath)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Dir(pth)
+	if filepath.Base(dir) == "fastlane" {
+		dir = filepath.Dir(dir)
+	}
+	return dir, nil
+}
+
+// rubyString returns s as a single-quoted Ruby string literal.
+func rubyString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
+
+// matchfileContent renders the effective configuration of a fetch run as a Matchfile,
+// leaving out the secrets.
+func (configs ConfigsModel) matchfileContent(platform string) string {
+	lines := []string{"# Generated by the Fastlane Match step"}
+
+	add := func(option, value string) {
+		if value != "" {
+			lines = append(lines, fmt.Sprintf("%s(%s)", option, rubyString(value)))
+		}
+	}
+
+	addBool := func(option, value string) {
+		if value == "yes" {
+			lines = append(lines, fmt.Sprintf("%s(true)", option))
+		}
+	}
+
+	types := splitList(configs.Type)
+	if len(types) > 1 {
+		lines = append(lines, fmt.Sprintf("# Types fetched on CI: %s", strings.Join(types, ", ")))
+	}
+	if len(types) > 0 {
+		add("type", types[0])
+	}
+
+	appIDs := []string{}
+	for _, appID := range splitList(configs.AppID) {
+		appIDs = append(appIDs, rubyString(appID))
+	}
+	if len(appIDs) > 0 {
+		lines = append(lines, fmt.Sprintf("app_identifier([%s])", strings.Join(appIDs, ", ")))
+	}
+
+	if !configs.usesMatchfileStorage() {
+		add("storage_mode", configs.StorageMode)
+
+		switch configs.StorageMode {
+		case "git":
+			add("git_url", configs.GitURL)
+			add("git_branch", configs.storageBranch())
+			addBool("shallow_clone", configs.ShallowClone)
+			if configs.cloneBranchDirectly() {
+				addBool("clone_branch_directly", "yes")
+			}
+		case "s3":
+			add("s3_bucket", configs.S3Bucket)
+			add("s3_region", configs.S3Region)
+			add("s3_object_prefix", configs.S3ObjectPrefix)
+		case "google_cloud":
+			add("google_cloud_bucket_name", configs.GoogleCloudBucketName)
+			add("google_cloud_project_id", configs.GoogleCloudProjectID)
+		case "gitlab_secure_files":
+			add("gitlab_project", configs.GitlabProject)
+			add("gitlab_host", configs.GitlabHost)
+		}
+	}
+
+	add("username", configs.AppleID)
+	add("team_id", configs.TeamID)
+	add("team_name", configs.TeamName)
+	add("platform", platform)
+	add("template_name", configs.TemplateName)
+	add("profile_name", configs.ProfileName)
+	addBool("readonly", configs.Readonly)
+	addBool("force", configs.Force)
+	addBool("force_for_new_devices", configs.ForceForNewDevices)
+	addBool("skip_provisioning_profiles", configs.SkipProvisioningProfiles)
+	addBool("skip_certificate_matching", configs.SkipCertificateMatching)
+	addBool("include_mac_in_profiles", configs.IncludeMacInProfiles)
+	addBool("fail_on_name_taken", configs.FailOnNameTaken)
+	addBool("derive_catalyst_app_identifier", configs.DeriveCatalystAppIdentifier)
+
+	if additionalCertTypes := splitList(configs.AdditionalCertTypes); len(additionalCertTypes) > 0 {
+		quoted := []string{}
+		for _, certType := range additionalCertTypes {
+			quoted = append(quoted, rubyString(certType))
+		}
+		lines = append(lines, fmt.Sprintf("additional_cert_types([%s])", strings.Join(quoted, ", ")))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bitrise-tools/go-steputils/input"
+	"github.com/kballard/go-shellquote"
+	"gopkg.in/yaml.v2"
+)
+
+// matrixItemModel describes a single match invocation of the matrix configuration.
+type matrixItemModel struct {
+	Type           string   `yaml:"type"`
+	Platform       string   `yaml:"platform"`
+	AppIdentifiers []string `yaml:"app_identifiers"`
+	Readonly       *bool    `yaml:"readonly"`
+	Options        string   `yaml:"options"`
+}
+
+// parseMatchMatrix reads the matrix configuration, given either inline or as a path to a file,
+// as a YAML or JSON list (JSON is valid YAML).
+func parseMatchMatrix(matrix string) ([]matchGroup, error) {
+	content := strings.TrimSpace(matrix)
+	if !strings.Contains(content, "\n") {
+		// A single line is either an inline flow list or a path to a file
+		if info, err := os.Stat(content); err == nil && !info.IsDir() {
+			b, err := ioutil.ReadFile(content)
+			if err != nil {
+				return nil, err
+			}
+			content = string(b)
+		}
+	}
+
+	var items []matrixItemModel
+	if err := yaml.Unmarshal([]byte(content), &items); err != nil {
+		return nil, fmt.Errorf("neither a YAML or JSON list nor a path to an existing file, error: %s", err)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no items defined")
+	}
+
+	groups := []matchGroup{}
+	for i, item := range items {
+		if err := input.ValidateWithOptions(item.Type, "adhoc", "appstore", "development", "enterprise"); err != nil {
+			return nil, fmt.Errorf("item %d, type %s", i, err)
+		}
+
+		if item.Platform != "" {
+			if err := input.ValidateWithOptions(item.Platform, "ios", "macos", "tvos", "catalyst"); err != nil {
+				return nil, fmt.Errorf("item %d, platform %s", i, err)
+			}
+		}
+
+		appIDs := []string{}
+		for _, appID := range item.AppIdentifiers {
+			if trimmed := strings.TrimSpace(appID); trimmed != "" {
+				appIDs = append(appIDs, trimmed)
+			}
+		}
+		if len(appIDs) == 0 {
+			return nil, fmt.Errorf("item %d, no app identifiers defined", i)
+		}
+
+		options, err := shellquote.Split(item.Options)
+		if err != nil {
+			return nil, fmt.Errorf("item %d, failed to split options (%s), error: %s", i, item.Options, err)
+		}
+
+		groups = append(groups, matchGroup{
+			Type:     item.Type,
+			AppIDs:   appIDs,
+			Platform: item.Platform,
+			Readonly: item.Readonly,
+			Options:  options,
+		})
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseMatchMatrix(t *testing.T) {
+	readonly := false
+	want := []matchGroup{
+		{Type: "appstore", AppIDs: []string{"com.foo.app", "com.foo.app.widget"}, Options: []string{}},
+		{Type: "development", AppIDs: []string{"com.foo.mac"}, Platform: "macos", Readonly: &readonly, Options: []string{"--force"}},
+	}
+
+	tests := []struct {
+		name   string
+		matrix string
+	}{
+		{
+			name: "inline yaml",
+			matrix: `
+- type: appstore
+  app_identifiers: [com.foo.app, com.foo.app.widget]
+- type: development
+  platform: macos
+  app_identifiers:
+    - com.foo.mac
+  readonly: false
+  options: --force
+`,
+		},
+		{
+			name: "inline json",
+			matrix: `[{"type": "appstore", "app_identifiers": ["com.foo.app", "com.foo.app.widget"]},` +
+				` {"type": "development", "platform": "macos", "app_identifiers": ["com.foo.mac"], "readonly": false, "options": "--force"}]`,
+		},
+		{
+			name:   "file path",
+			matrix: filepath.Join("testdata", "matrix", "matrix.yml") + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := parseMatchMatrix(tt.matrix)
+			if err != nil {
+				t.Fatalf("parseMatchMatrix() error = %s", err)
+			}
+			if !reflect.DeepEqual(groups, want) {
+				t.Errorf("parseMatchMatrix() = %+v, want %+v", groups, want)
+			}
+		})
+	}
+}
+
+func TestParseMatchMatrixInvalid(t *testing.T) {
+	for _, matrix := range []string{
+		filepath.Join("testdata", "matrix", "missing.yml"),
+		"[]",
+		"- type: appstore",
+		"- type: unknown\n  app_identifiers: [com.foo.app]",
+		"- {type: appstore, app_identifiers: [com.foo.app], options: \"'unclosed\"}",
+	} {
+		if _, err := parseMatchMatrix(matrix); err == nil {
+			t.Errorf("parseMatchMatrix(%q) error = nil, want an error", matrix)
+		}
+	}
+}
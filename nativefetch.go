@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// matchProfileTypeNames are the profile file name prefixes match uses in the storage.
+var matchProfileTypeNames = map[string]string{
+	"development": "Development",
+	"adhoc":       "AdHoc",
+	"appstore":    "AppStore",
+	"enterprise":  "InHouse",
+}
+
+// matchCertificateType returns the storage directory of the certificates of the match type.
+func matchCertificateType(matchType string) string {
+	switch matchType {
+	case "development":
+		return "development"
+	case "enterprise":
+		return "enterprise"
+	}
+	return "distribution"
+}
+
+// matchProfileFileName returns the storage file name of the profile, the way match names it.
+func matchProfileFileName(matchType, appID, platform string) string {
+	names := []string{matchProfileTypeNames[matchType], appID}
+	ext := ".mobileprovision"
+	if platform != "" && platform != "ios" {
+		names = append(names, platform)
+	}
+	if platform == "macos" || platform == "catalyst" {
+		ext = ".provisionprofile"
+	}
+	return strings.Join(names, "_") + ext
+}
+
+// nativeStorageModel is a decrypted clone of the git storage.
+type nativeStorageModel struct {
+	Dir      string
+	Password string
+}
+
+// cloneNativeStorage clones the branch, tag or commit of the git storage
+// into a temporary directory, removed on exit.
+func cloneNativeStorage(url, branch, privateKeyPath, basicAuthorization, password string) (nativeStorageModel, error) {
+	if branch == "" {
+		branch = "master"
+	}
+
+	tmpDir, err := pathutil.NormalizedOSTempDirPath("match_storage")
+	if err != nil {
+		return nativeStorageModel{}, err
+	}
+	registerCleanup(func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warnf("Failed to remove the storage clone, error: %s", err)
+		}
+	})
+
+	cmdArgs := [][]string{{"clone", "--depth", "1", "--single-branch", "--branch", branch, url, tmpDir}}
+	if isCommitSHA(branch) {
+		// A commit can't be cloned directly, it is fetched into an empty repository
+		cmdArgs = [][]string{
+			{"init", tmpDir},
+			{"-C", tmpDir, "fetch", "--depth", "1", url, branch},
+			{"-C", tmpDir, "checkout", "--detach", "FETCH_HEAD"},
+		}
+	}
+
+	for _, args := range cmdArgs {
+		cmd := gitCommand(privateKeyPath, basicAuthorization, args...)
+		if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+			return nativeStorageModel{}, fmt.Errorf("failed to clone the storage, output: %s, error: %s", out, err)
+		}
+	}
+
+	return nativeStorageModel{Dir: tmpDir, Password: password}, nil
+}
+
+// decryptTo decrypts the storage file into the output directory.
+func (storage nativeStorageModel) decryptTo(relPth, outputDir string) (string, error) {
+	content, err := fileutil.ReadStringFromFile(filepath.Join(storage.Dir, relPth))
+	if err != nil {
+		return "", err
+	}
+
+	decrypted, err := decryptMatchFile(content, storage.Password)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s, error: %s", relPth, err)
+	}
+
+	pth := filepath.Join(outputDir, filepath.Base(relPth))
+	if err := fileutil.WriteBytesToFileWithPermission(pth, decrypted, 0600); err != nil {
+		return "", err
+	}
+	return pth, nil
+}
+
+// teamID returns the team ID of the stored certificates, empty if the storage has no certificates.
+// It fails if the certificates belong to multiple teams.
+func (storage nativeStorageModel) teamID() (string, error) {
+	cers, err := filepath.Glob(filepath.Join(storage.Dir, "certs", "*", "*.cer"))
+	if err != nil {
+		return "", err
+	}
+
+	teamIDs := []string{}
+	seen := map[string]bool{}
+	for _, cer := range cers {
+		content, err := fileutil.ReadStringFromFile(cer)
+		if err != nil {
+			return "", err
+		}
+
+		decrypted, err := decryptMatchFile(content, storage.Password)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt %s, error: %s", filepath.Base(cer), err)
+		}
+
+		cert, err := x509.ParseCertificate(decrypted)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s, error: %s", filepath.Base(cer), err)
+		}
+
+		if teamID := newCertificateModel(cer, cert).TeamID; teamID != "" && !seen[teamID] {
+			seen[teamID] = true
+			teamIDs = append(teamIDs, teamID)
+		}
+	}
+
+	if len(teamIDs) > 1 {
+		return "", fmt.Errorf("the certificates belong to multiple teams: %s", strings.Join(teamIDs, ", "))
+	}
+	if len(teamIDs) == 0 {
+		return "", nil
+	}
+	return teamIDs[0], nil
+}
+
+// nativeFetch installs the certificates and the provisioning profiles of the group
+// from the storage clone, without fastlane. Only the readonly flow is supported.
+func nativeFetch(storage nativeStorageModel, group matchGroup, platform, outputDir, keychain, keychainPassword string, setPartitionList, skipProfiles bool) error {
+	certDir := filepath.Join("certs", matchCertificateType(group.Type))
+	cers, err := filepath.Glob(filepath.Join(storage.Dir, certDir, "*.cer"))
+	if err != nil {
+		return err
+	}
+	if len(cers) == 0 {
+		return fmt.Errorf("no %s certificate found in the storage", matchCertificateType(group.Type))
+	}
+
+	for _, cer := range cers {
+		id := strings.TrimSuffix(filepath.Base(cer), ".cer")
+
+		cerPth, err := storage.decryptTo(filepath.Join(certDir, id+".cer"), outputDir)
+		if err != nil {
+			return err
+		}
+
+		p12Pth, err := storage.decryptTo(filepath.Join(certDir, id+".p12"), outputDir)
+		if err != nil {
+			return err
+		}
+
+		if err := installCertificate(cerPth, p12Pth, "", keychain); err != nil {
+			return err
+		}
+
+		if err := os.Remove(p12Pth); err != nil {
+			log.Warnf("Failed to remove the decrypted private key, error: %s", err)
+		}
+
+		log.Printf("Installed certificate: %s", id)
+	}
+
+	if setPartitionList {
+		if err := setKeyPartitionList(keychain, keychainPassword); err != nil {
+			return err
+		}
+	}
+
+	if skipProfiles {
+		return nil
+	}
+
+	for _, appID := range group.AppIDs {
+		relPth := filepath.Join("profiles", group.Type, matchProfileFileName(group.Type, appID, platform))
+		if exist, err := pathutil.IsPathExists(filepath.Join(storage.Dir, relPth)); err != nil {
+			return err
+		} else if !exist {
+			return errors.New("no matching provisioning profile found in the storage: " + relPth)
+		}
+
+		pth, err := storage.decryptTo(relPth, outputDir)
+		if err != nil {
+			return err
+		}
+
+		if _, err := installProfile(pth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// The storage fixtures hold self-signed certificates with the organizational unit of the Apple ones,
+// encrypted by `openssl enc -aes-256-cbc -md md5` with the password of the matchcrypto tests.
+func TestStorageTeamID(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		{name: "single team", dir: filepath.Join("testdata", "storage"), want: "9Z8Y7X6W5V"},
+		{name: "multiple teams", dir: filepath.Join("testdata", "storage_teams"), wantErr: true},
+		{name: "no certificates", dir: filepath.Join("testdata", "Sample"), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teamID, err := nativeStorageModel{Dir: tt.dir, Password: testMatchPassword}.teamID()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("teamID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if teamID != tt.want {
+				t.Errorf("teamID() = %s, want %s", teamID, tt.want)
+			}
+		})
+	}
+
+	if _, err := (nativeStorageModel{Dir: filepath.Join("testdata", "storage"), Password: "wrong-password"}).teamID(); err == nil {
+		t.Errorf("teamID() with a wrong password error = nil, want an error")
+	}
+}
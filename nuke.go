@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bitrise-tools/go-steputils/input"
+)
+
+// validateNuke checks the inputs required to revoke and delete the signing assets.
+// The confirmation input must repeat the nuked type, to prevent accidental runs.
+func (configs ConfigsModel) validateNuke() error {
+	if err := input.ValidateWithOptions(configs.NukeType, "development", "distribution", "enterprise"); err != nil {
+		return fmt.Errorf("Nuke type, %s", err)
+	}
+
+	expectedConfirmation := fmt.Sprintf("nuke %s", configs.NukeType)
+	if configs.NukeConfirmation != expectedConfirmation {
+		return fmt.Errorf("Nuke confirmation, must be set to: %s", expectedConfirmation)
+	}
+
+	return nil
+}
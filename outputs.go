@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-tools/go-steputils/tools"
+)
+
+const outputListSeparator = "|"
+
+func exportOutput(key, value string) error {
+	log.Printf("- %s: %s", key, value)
+	return tools.ExportEnvironmentWithEnvman(key, value)
+}
+
+// exportSecretOutput exports a sensitive value without printing it.
+func exportSecretOutput(key, value string) error {
+	log.Printf("- %s: ***", key)
+	return tools.ExportEnvironmentWithEnvman(key, value)
+}
+
+// exportResult exports the outcome of the run, for the run_if conditions of the later steps.
+func exportResult(result, reason string) {
+	if err := tools.ExportEnvironmentWithEnvman("MATCH_RESULT", result); err != nil {
+		log.Warnf("Failed to export MATCH_RESULT, error: %s", err)
+	}
+	if err := tools.ExportEnvironmentWithEnvman("MATCH_RESULT_REASON", reason); err != nil {
+		log.Warnf("Failed to export MATCH_RESULT_REASON, error: %s", err)
+	}
+}
+
+// exportProfileOutputs exports the details of the fetched provisioning profiles.
+func exportProfileOutputs(profiles []ProfileModel) error {
+	uuids := []string{}
+	for _, profile := range profiles {
+		uuids = append(uuids, profile.UUID)
+	}
+
+	return exportOutput("MATCH_PROFILE_UUIDS", strings.Join(uuids, outputListSeparator))
+}
+
+// exportCertificateOutputs exports the identities of the fetched certificates.
+func exportCertificateOutputs(certificates []CertificateModel) error {
+	identities := []string{}
+	sha1s := []string{}
+	for _, certificate := range certificates {
+		identities = append(identities, certificate.CommonName)
+		sha1s = append(sha1s, certificate.SHA1)
+	}
+
+	identity := ""
+	if len(identities) > 0 {
+		identity = identities[0]
+	}
+
+	if err := exportOutput("MATCH_CODESIGN_IDENTITY", identity); err != nil {
+		return err
+	}
+
+	if err := exportOutput("MATCH_CODESIGN_IDENTITIES", strings.Join(identities, outputListSeparator)); err != nil {
+		return err
+	}
+
+	return exportOutput("MATCH_CODESIGN_IDENTITY_SHA1S", strings.Join(sha1s, outputListSeparator))
+}
+
+// exportSigningOutputs exports the code signing settings consumed by the Xcode steps,
+// the profile settings refer to the last fetched type and the first app identifier.
+func exportSigningOutputs(types, appIDs []string, teamID string, profiles []ProfileModel) error {
+	matchType := ""
+	if len(types) > 0 {
+		matchType = types[len(types)-1]
+	}
+
+	profileSpecifier := ""
+	profileSpecifiers := []string{}
+	for _, appID := range appIDs {
+		for _, profile := range profiles {
+			if profile.Type != matchType || profile.AppID != appID {
+				continue
+			}
+
+			if profileSpecifier == "" {
+				profileSpecifier = profile.Name
+			}
+			if teamID == "" {
+				teamID = profile.TeamID
+			}
+			profileSpecifiers = append(profileSpecifiers, fmt.Sprintf("%s=%s", appID, profile.Name))
+			break
+		}
+	}
+
+	if err := exportOutput("MATCH_TEAM_ID", teamID); err != nil {
+		return err
+	}
+
+	if err := exportOutput("MATCH_PROFILE_SPECIFIER", profileSpecifier); err != nil {
+		return err
+	}
+
+	return exportOutput("MATCH_PROFILE_SPECIFIERS", strings.Join(profileSpecifiers, outputListSeparator))
+}
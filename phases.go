@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// phaseModel is a timed part of the step run.
+type phaseModel struct {
+	Name     string
+	Duration time.Duration
+	Outcome  string
+}
+
+var (
+	phases           []phaseModel
+	currentPhase     string
+	currentPhaseTime time.Time
+)
+
+// startPhase finishes the current phase as succeeded and starts timing the next one.
+func startPhase(name string) {
+	finishPhase("succeeded")
+	currentPhase = name
+	currentPhaseTime = time.Now()
+}
+
+// finishPhase records the current phase, if any, with the given outcome.
+func finishPhase(outcome string) {
+	if currentPhase == "" {
+		return
+	}
+
+	phases = append(phases, phaseModel{
+		Name:     currentPhase,
+		Duration: time.Since(currentPhaseTime),
+		Outcome:  outcome,
+	})
+	currentPhase = ""
+}
+
+// printPhaseSummary finishes the current phase with the given outcome and logs the duration of every phase.
+func printPhaseSummary(outcome string) {
+	finishPhase(outcome)
+	if len(phases) == 0 {
+		return
+	}
+
+	var total time.Duration
+	for _, phase := range phases {
+		total += phase.Duration
+	}
+
+	fmt.Println()
+	log.Infof("Summary")
+	for _, phase := range phases {
+		log.Printf("- %-16s %8.2fs  %s", phase.Name, phase.Duration.Seconds(), phase.Outcome)
+	}
+	log.Printf("- %-16s %8.2fs", "total", total.Seconds())
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/kballard/go-shellquote"
+)
+
+// gitAccessFailures map the git ls-remote errors to a precise reason, checked in order.
+var gitAccessFailures = []struct {
+	Pattern *regexp.Regexp
+	Reason  string
+}{
+	{regexp.MustCompile(`(?i)host key verification failed`), "host key verification failed"},
+	{regexp.MustCompile(`(?i)could not resolve host(name)?`), "host not found"},
+	{regexp.MustCompile(`(?i)repository not found|does not appear to be a git repository|does not exist`), "repository not found"},
+	{regexp.MustCompile(`(?i)permission denied|authentication failed|could not read (username|password)|terminal prompts disabled|\b40[13]\b`), "auth failed"},
+	{regexp.MustCompile(`(?i)timed out|connection (refused|reset)`), "connection failed"},
+}
+
+func gitAccessFailureReason(out string) string {
+	for _, failure := range gitAccessFailures {
+		if failure.Pattern.MatchString(out) {
+			return failure.Reason
+		}
+	}
+	return "unknown error"
+}
+
+// gitSSHCommand returns the ssh command git should use, authenticating only with the key if given.
+// BatchMode makes ssh fail instead of prompting for a password or passphrase.
+func gitSSHCommand(privateKeyPath string) string {
+	sshCommand := "ssh -o BatchMode=yes"
+	if privateKeyPath != "" {
+		sshCommand += " -o IdentitiesOnly=yes -i " + shellquote.Join(privateKeyPath)
+	}
+	return sshCommand
+}
+
+// gitCommand returns a non-interactive git command, authenticated the way match does it.
+func gitCommand(privateKeyPath, basicAuthorization string, args ...string) *command.Model {
+	gitArgs := []string{}
+	if basicAuthorization != "" {
+		gitArgs = append(gitArgs, "-c", "http.extraheader=Authorization: Basic "+basicAuthorization)
+	}
+	gitArgs = append(gitArgs, args...)
+
+	return command.New("git", gitArgs...).AppendEnvs(
+		"GIT_SSH_COMMAND="+gitSSHCommand(privateKeyPath),
+		"GIT_TERMINAL_PROMPT=0",
+	)
+}
+
+// checkGitAccess runs git ls-remote against the storage with the credentials match would use,
+// and returns the first of the branches, in priority order, that exists. If none exists,
+// the first branch is returned for match to create it, except in readonly mode,
+// the returned bool reports whether the branch exists.
+func checkGitAccess(url string, branches []string, privateKeyPath, basicAuthorization string, readonly bool) (string, bool, error) {
+	if len(branches) == 0 {
+		branches = []string{"master"}
+	}
+
+	cmd := gitCommand(privateKeyPath, basicAuthorization, append([]string{"ls-remote", "--heads", url}, branches...)...)
+
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", false, fmt.Errorf("%s, output: %s", gitAccessFailureReason(out), out)
+	}
+
+	heads := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			heads[strings.TrimPrefix(fields[1], "refs/heads/")] = true
+		}
+	}
+
+	for _, branch := range branches {
+		if heads[branch] {
+			return branch, true, nil
+		}
+	}
+
+	if readonly {
+		return "", false, errors.New("branch not found: " + strings.Join(branches, ", "))
+	}
+	log.Warnf("Branch not found: %s, match will create it", branches[0])
+
+	return branches[0], false, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-xcode/plistutil"
+	"github.com/fullsailor/pkcs7"
+	"howett.net/plist"
+)
+
+// provisioningProfileDirs are the directories profiles are installed to,
+// the first one is used by Xcode 16 and later.
+var provisioningProfileDirs = []string{
+	filepath.Join(pathutil.UserHomeDir(), "Library/Developer/Xcode/UserData/Provisioning Profiles"),
+	filepath.Join(pathutil.UserHomeDir(), "Library/MobileDevice/Provisioning Profiles"),
+}
+
+// ProfileModel ...
+type ProfileModel struct {
+	Path           string
+	UUID           string
+	Name           string
+	TeamID         string
+	AppID          string
+	Type           string
+	ExpirationDate time.Time
+	DeviceCount    int
+	XcodeManaged   bool
+	Entitlements   map[string]interface{}
+	// CertificateSHA1s are the fingerprints of the certificates included in the profile
+	CertificateSHA1s []string
+}
+
+func parseProfile(pth string) (ProfileModel, error) {
+	content, err := fileutil.ReadBytesFromFile(pth)
+	if err != nil {
+		return ProfileModel{}, err
+	}
+
+	signed, err := pkcs7.Parse(content)
+	if err != nil {
+		return ProfileModel{}, fmt.Errorf("failed to parse the provisioning profile %s, error: %s", pth, err)
+	}
+
+	var profile plistutil.PlistData
+	if _, err := plist.Unmarshal(signed.Content, &profile); err != nil {
+		return ProfileModel{}, fmt.Errorf("failed to parse the provisioning profile %s, error: %s", pth, err)
+	}
+
+	expirationDate, _ := profile.GetTime("ExpirationDate")
+	if expirationDate.IsZero() {
+		return ProfileModel{}, fmt.Errorf("failed to parse the ExpirationDate of the provisioning profile: %s", pth)
+	}
+
+	entitlements, _ := profile.GetMapStringInterface("Entitlements")
+
+	appID, ok := entitlements.GetString("application-identifier")
+	if !ok {
+		appID, _ = entitlements.GetString("com.apple.application-identifier")
+	}
+	if prefixes, _ := profile.GetStringArray("ApplicationIdentifierPrefix"); len(prefixes) > 0 {
+		appID = strings.TrimPrefix(appID, prefixes[0]+".")
+	}
+
+	teamID := ""
+	if teamIDs, _ := profile.GetStringArray("TeamIdentifier"); len(teamIDs) > 0 {
+		teamID = teamIDs[0]
+	}
+
+	uuid, _ := profile.GetString("UUID")
+	name, _ := profile.GetString("Name")
+	provisionedDevices, _ := profile.GetStringArray("ProvisionedDevices")
+	xcodeManaged, _ := profile.GetBool("IsXcodeManaged")
+
+	certificateSHA1s := []string{}
+	developerCertificates, _ := profile.GetByteArrayArray("DeveloperCertificates")
+	for _, developerCertificate := range developerCertificates {
+		certificateSHA1s = append(certificateSHA1s, strings.ToUpper(fmt.Sprintf("%x", sha1.Sum(developerCertificate))))
+	}
+
+	return ProfileModel{
+		Path:             pth,
+		UUID:             uuid,
+		Name:             name,
+		TeamID:           teamID,
+		AppID:            appID,
+		Type:             profileType(profile, entitlements),
+		ExpirationDate:   expirationDate,
+		DeviceCount:      len(provisionedDevices),
+		XcodeManaged:     xcodeManaged,
+		Entitlements:     entitlements,
+		CertificateSHA1s: certificateSHA1s,
+	}, nil
+}
+
+// profileType returns the match type of the provisioning profile.
+func profileType(profile, entitlements plistutil.PlistData) string {
+	if provisionsAllDevices, _ := profile.GetBool("ProvisionsAllDevices"); provisionsAllDevices {
+		return "enterprise"
+	}
+
+	if _, ok := profile["ProvisionedDevices"]; ok {
+		if getTaskAllow, _ := entitlements.GetBool("get-task-allow"); getTaskAllow {
+			return "development"
+		}
+		return "adhoc"
+	}
+
+	return "appstore"
+}
+
+// findProfiles parses every provisioning profile in the directory.
+func findProfiles(dir string) ([]ProfileModel, error) {
+	pths := []string{}
+	if err := filepath.Walk(dir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(pth))
+		if !info.IsDir() && (ext == ".mobileprovision" || ext == ".provisionprofile") {
+			pths = append(pths, pth)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(pths)
+
+	profiles := []ProfileModel{}
+	for _, pth := range pths {
+		profile, err := parseProfile(pth)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// installedProfilePath returns the path the profile is installed to,
+// or the path it was parsed from if it is not installed.
+func installedProfilePath(profile ProfileModel) string {
+	for _, dir := range provisioningProfileDirs {
+		pth := filepath.Join(dir, profile.UUID+filepath.Ext(profile.Path))
+		if exist, err := pathutil.IsPathExists(pth); err == nil && exist {
+			return pth
+		}
+	}
+	return profile.Path
+}
+
+// installedProfileCopies returns every path the profile is installed to.
+func installedProfileCopies(profile ProfileModel) []string {
+	pths := []string{}
+	for _, dir := range provisioningProfileDirs {
+		pth := filepath.Join(dir, profile.UUID+filepath.Ext(profile.Path))
+		if exist, err := pathutil.IsPathExists(pth); err == nil && exist {
+			pths = append(pths, pth)
+		}
+	}
+	return pths
+}
+
+// printProfileTable prints the main attributes of the profiles, one row per profile.
+func printProfileTable(profiles []ProfileModel) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUUID\tTYPE\tTEAM\tAPP ID\tEXPIRES\tDEVICES\tXCODE MANAGED")
+	for _, profile := range profiles {
+		xcodeManaged := "no"
+		if profile.XcodeManaged {
+			xcodeManaged = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", profile.Name, profile.UUID, profile.Type, profile.TeamID, profile.AppID, profile.ExpirationDate.Format("2006-01-02"), profile.DeviceCount, xcodeManaged)
+	}
+	w.Flush()
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		log.Printf("%s", line)
+	}
+}
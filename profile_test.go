@@ -0,0 +1,111 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/plistutil"
+)
+
+// The fixtures are CMS signed provisioning profiles with the structure of the ones the
+// Apple Developer Portal generates, including a developer certificate.
+const testProfileCertificateSHA1 = "EE5715E03235A7B4021291582166676569079434"
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		pth  string
+		want ProfileModel
+	}{
+		{
+			pth: filepath.Join("testdata", "profiles", "development.mobileprovision"),
+			want: ProfileModel{
+				UUID:           "3F2504E0-4F89-41D3-9A0C-0305E82C3301",
+				Name:           "match Development io.platan.sample",
+				TeamID:         "9Z8Y7X6W5V",
+				AppID:          "io.platan.sample",
+				Type:           "development",
+				ExpirationDate: time.Date(2027, 1, 12, 14, 3, 27, 0, time.UTC),
+				DeviceCount:    2,
+			},
+		},
+		{
+			pth: filepath.Join("testdata", "profiles", "appstore.mobileprovision"),
+			want: ProfileModel{
+				UUID:           "7C9E6679-7425-40DE-944B-E07FC1F90AE7",
+				Name:           "match AppStore io.platan.sample.widget",
+				TeamID:         "9Z8Y7X6W5V",
+				AppID:          "io.platan.sample.widget",
+				Type:           "appstore",
+				ExpirationDate: time.Date(2027, 1, 12, 14, 3, 27, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.pth), func(t *testing.T) {
+			profile, err := parseProfile(tt.pth)
+			if err != nil {
+				t.Fatalf("parseProfile() error = %s", err)
+			}
+
+			if want := []string{testProfileCertificateSHA1}; !reflect.DeepEqual(profile.CertificateSHA1s, want) {
+				t.Errorf("CertificateSHA1s = %v, want %v", profile.CertificateSHA1s, want)
+			}
+			if profile.Entitlements["com.apple.developer.team-identifier"] != "9Z8Y7X6W5V" {
+				t.Errorf("Entitlements = %v", profile.Entitlements)
+			}
+
+			profile.Path, tt.want.Path = "", ""
+			profile.Entitlements = nil
+			profile.CertificateSHA1s = nil
+			if !reflect.DeepEqual(profile, tt.want) {
+				t.Errorf("parseProfile() = %+v, want %+v", profile, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProfileWithoutExpirationDate(t *testing.T) {
+	if _, err := parseProfile(filepath.Join("testdata", "invalid_profiles", "no_expiration_date.mobileprovision")); err == nil {
+		t.Errorf("parseProfile() error = nil, want an error")
+	}
+}
+
+func TestProfileType(t *testing.T) {
+	devices := []interface{}{"00008110-000A1C2E3F4B5D6E"}
+
+	tests := []struct {
+		name         string
+		profile      plistutil.PlistData
+		entitlements plistutil.PlistData
+		want         string
+	}{
+		{name: "appstore", profile: plistutil.PlistData{}, entitlements: plistutil.PlistData{"get-task-allow": false}, want: "appstore"},
+		{name: "adhoc", profile: plistutil.PlistData{"ProvisionedDevices": devices}, entitlements: plistutil.PlistData{"get-task-allow": false}, want: "adhoc"},
+		{name: "development", profile: plistutil.PlistData{"ProvisionedDevices": devices}, entitlements: plistutil.PlistData{"get-task-allow": true}, want: "development"},
+		{name: "enterprise", profile: plistutil.PlistData{"ProvisionsAllDevices": true}, entitlements: plistutil.PlistData{}, want: "enterprise"},
+	}
+
+	for _, tt := range tests {
+		if got := profileType(tt.profile, tt.entitlements); got != tt.want {
+			t.Errorf("profileType(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindProfiles(t *testing.T) {
+	profiles, err := findProfiles(filepath.Join("testdata", "profiles"))
+	if err != nil {
+		t.Fatalf("findProfiles() error = %s", err)
+	}
+
+	uuids := []string{}
+	for _, profile := range profiles {
+		uuids = append(uuids, profile.UUID)
+	}
+	if want := []string{"7C9E6679-7425-40DE-944B-E07FC1F90AE7", "3F2504E0-4F89-41D3-9A0C-0305E82C3301"}; !reflect.DeepEqual(uuids, want) {
+		t.Errorf("findProfiles() = %v, want %v", uuids, want)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// nonRetryableFailurePatterns match failures retrying can not fix,
+// checked before the retryable ones.
+var nonRetryableFailurePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)invalid username and password`),
+	regexp.MustCompile(`(?i)authentication failed`),
+	regexp.MustCompile(`(?i)permission denied \(publickey\)`),
+	regexp.MustCompile(`(?i)couldn't decrypt`),
+	regexp.MustCompile(`(?i)invalid password`),
+	regexp.MustCompile(`(?i)\b401\b.*unauthorized`),
+}
+
+// retryableFailurePatterns match the network errors and server side failures.
+var retryableFailurePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)timed? ?out`),
+	regexp.MustCompile(`(?i)connection (reset|refused|closed)`),
+	regexp.MustCompile(`(?i)could not resolve host`),
+	regexp.MustCompile(`(?i)failed to connect`),
+	regexp.MustCompile(`(?i)SocketError|Net::OpenTimeout|Net::ReadTimeout|Faraday::ConnectionFailed|EOFError`),
+	regexp.MustCompile(`(?i)the remote end hung up unexpectedly`),
+	// The status codes only in an HTTP context, a build number or a count can be 500 too
+	regexp.MustCompile(`(?i)(status|http|response|code)[^\n]{0,20}\b50[0234]\b`),
+	regexp.MustCompile(`(?i)internal server error|bad gateway|service unavailable|gateway timeout`),
+}
+
+// isRetryableFailure reports whether the fastlane output points to a transient failure.
+func isRetryableFailure(out string) bool {
+	for _, exp := range nonRetryableFailurePatterns {
+		if exp.MatchString(out) {
+			return false
+		}
+	}
+
+	for _, exp := range retryableFailurePatterns {
+		if exp.MatchString(out) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runFastlaneWithRetry runs fastlane and retries transient failures up to retryCount times,
+// doubling the wait between the attempts.
+func runFastlaneWithRetry(retryCount int, wait time.Duration, fastlaneCmdSlice []string, workDir string, args, envs, secrets []string) (string, error) {
+	for attempt := 0; ; attempt++ {
+		out, err := runFastlane(fastlaneCmdSlice, workDir, args, envs, secrets)
+		if err == nil || attempt >= retryCount {
+			return out, err
+		}
+
+		if !isRetryableFailure(out) {
+			return out, err
+		}
+
+		log.Warnf("Attempt %d failed with a transient error, retrying in %s...", attempt+1, wait)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestIsRetryableFailure(t *testing.T) {
+	tests := []struct {
+		out  string
+		want bool
+	}{
+		{out: "Net::ReadTimeout with #<TCPSocket:(closed)>", want: true},
+		{out: "fatal: the remote end hung up unexpectedly", want: true},
+		{out: "Server error: HTTP status code 503", want: true},
+		{out: "the server responded with status 502", want: true},
+		{out: "Apple returned 504 Gateway Timeout", want: true},
+		{out: "Updating build number to 500", want: false},
+		{out: "Found 502 devices on the portal", want: false},
+		{out: "No matching provisioning profiles found for fastlane 2.504.0", want: false},
+		{out: "Invalid username and password combination, HTTP status code 503", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isRetryableFailure(tt.out); got != tt.want {
+			t.Errorf("isRetryableFailure(%q) = %t, want %t", tt.out, got, tt.want)
+		}
+	}
+}
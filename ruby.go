@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/versions"
+)
+
+// rubyVersionManager is a Ruby version manager available on the Bitrise stacks.
+type rubyVersionManager struct {
+	Name string
+	// ListArgs list the installed versions, one per line
+	ListArgs []string
+	// VersionEnv selects the version for the processes started by the step
+	VersionEnv string
+}
+
+var rubyVersionManagers = []rubyVersionManager{
+	{Name: "asdf", ListArgs: []string{"list", "ruby"}, VersionEnv: "ASDF_RUBY_VERSION"},
+	{Name: "rbenv", ListArgs: []string{"versions", "--bare"}, VersionEnv: "RBENV_VERSION"},
+}
+
+var (
+	rubyReleaseVersionExp = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+	rubyVersionOutputExp  = regexp.MustCompile(`ruby (\d+\.\d+\.\d+)`)
+)
+
+// latestRubyVersion returns the latest of the installed versions matching the requested one,
+// which can be a prefix like 3.2.
+func latestRubyVersion(installed []string, requested string) string {
+	latest := ""
+	for _, version := range installed {
+		if version != requested && !strings.HasPrefix(version, requested+".") {
+			continue
+		}
+		if !rubyReleaseVersionExp.MatchString(version) {
+			continue
+		}
+		if latest == "" {
+			latest = version
+		} else if cmp, err := versions.CompareVersions(latest, version); err == nil && cmp == 1 {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// installedRubyVersions parses the output of the version manager's list command.
+func installedRubyVersions(out string) []string {
+	installed := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if version := strings.TrimLeft(strings.TrimSpace(line), "* "); version != "" {
+			installed = append(installed, version)
+		}
+	}
+	return installed
+}
+
+// selectRuby makes the version manager of the stack use the requested Ruby version for the rest of the step,
+// installing it if no matching version is installed and an exact version is requested.
+func selectRuby(requested string) (string, error) {
+	for _, manager := range rubyVersionManagers {
+		if _, err := exec.LookPath(manager.Name); err != nil {
+			continue
+		}
+
+		out, err := command.New(manager.Name, manager.ListArgs...).RunAndReturnTrimmedCombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("failed to list the installed Ruby versions with %s, output: %s", manager.Name, out)
+		}
+
+		version := latestRubyVersion(installedRubyVersions(out), requested)
+		if version == "" {
+			if !rubyReleaseVersionExp.MatchString(requested) {
+				return "", fmt.Errorf("no installed Ruby version matches %s, set an exact version to install it", requested)
+			}
+
+			log.Printf("Ruby %s is not installed, installing it with %s...", requested, manager.Name)
+			cmd := command.NewWithStandardOuts(manager.Name, "install", "ruby", requested)
+			if manager.Name == "rbenv" {
+				cmd = command.NewWithStandardOuts(manager.Name, "install", requested)
+			}
+			if err := cmd.Run(); err != nil {
+				return "", fmt.Errorf("failed to install Ruby %s, error: %s", requested, err)
+			}
+			version = requested
+		}
+
+		log.Printf("Using Ruby %s with %s", version, manager.Name)
+		if err := os.Setenv(manager.VersionEnv, version); err != nil {
+			return "", err
+		}
+
+		return activeRubyVersion()
+	}
+
+	return "", fmt.Errorf("neither asdf nor rbenv is available to select Ruby %s", requested)
+}
+
+// activeRubyVersion returns the version of the ruby on the PATH.
+func activeRubyVersion() (string, error) {
+	out, err := command.New("ruby", "--version").RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the Ruby version, output: %s, error: %s", out, err)
+	}
+
+	match := rubyVersionOutputExp.FindStringSubmatch(out)
+	if match == nil {
+		return "", fmt.Errorf("unexpected ruby --version output: %s", out)
+	}
+	return match[1], nil
+}
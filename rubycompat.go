@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/versions"
+)
+
+const defaultGemSource = "https://rubygems.org"
+
+var numericVersionExp = regexp.MustCompile(`^\d+(\.\d+)*$`)
+
+// rubyGemVersionModel is the part of the RubyGems API gem version details the step uses.
+type rubyGemVersionModel struct {
+	Version     string `json:"version"`
+	RubyVersion string `json:"ruby_version"`
+}
+
+func getRubyGemVersion(url string) (rubyGemVersionModel, error) {
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return rubyGemVersionModel{}, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body, error: %s", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return rubyGemVersionModel{}, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+	}
+
+	var version rubyGemVersionModel
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return rubyGemVersionModel{}, fmt.Errorf("failed to parse response, error: %s", err)
+	}
+	return version, nil
+}
+
+// rubyGemsAPIURL returns the RubyGems API of the gem source, rubygems.org if not set.
+func rubyGemsAPIURL(gemSource string) string {
+	if gemSource == "" {
+		gemSource = defaultGemSource
+	}
+	return strings.TrimSuffix(gemSource, "/") + "/api"
+}
+
+// fastlaneRubyRequirement returns the resolved fastlane version and its required Ruby version, like ">= 2.6",
+// as published by the API of the gem source.
+func fastlaneRubyRequirement(gemSource, fastlaneVersion string) (string, string, error) {
+	apiURL := rubyGemsAPIURL(gemSource)
+
+	if fastlaneVersion == "latest" {
+		latest, err := getRubyGemVersion(apiURL + "/v1/versions/fastlane/latest.json")
+		if err != nil {
+			return "", "", err
+		}
+		fastlaneVersion = latest.Version
+	}
+
+	version, err := getRubyGemVersion(fmt.Sprintf("%s/v2/rubygems/fastlane/versions/%s.json", apiURL, fastlaneVersion))
+	if err != nil {
+		return "", "", err
+	}
+	return fastlaneVersion, version.RubyVersion, nil
+}
+
+// rubyRequirementSatisfied checks a version against a RubyGems requirement, like ">= 2.6, < 4".
+func rubyRequirementSatisfied(version, requirement string) (bool, error) {
+	for _, constraint := range strings.Split(requirement, ",") {
+		fields := strings.Fields(constraint)
+		if len(fields) == 1 {
+			fields = []string{"=", fields[0]}
+		}
+		if len(fields) != 2 || !numericVersionExp.MatchString(fields[1]) {
+			return false, fmt.Errorf("unsupported requirement: %s", constraint)
+		}
+
+		// 1: version < required, 0: equal, -1: version > required
+		cmp, err := versions.CompareVersions(version, fields[1])
+		if err != nil {
+			return false, err
+		}
+
+		satisfied := false
+		switch fields[0] {
+		case ">=":
+			satisfied = cmp <= 0
+		case ">":
+			satisfied = cmp < 0
+		case "<=":
+			satisfied = cmp >= 0
+		case "<":
+			satisfied = cmp > 0
+		case "=":
+			satisfied = cmp == 0
+		case "!=":
+			satisfied = cmp != 0
+		case "~>":
+			// ~> 2.6 means >= 2.6 and < 3, ~> 2.6.1 means >= 2.6.1 and < 2.7
+			parts := strings.Split(fields[1], ".")
+			prefix := strings.Join(parts[:len(parts)-1], ".")
+			satisfied = cmp <= 0 && (len(parts) == 1 || version == prefix || strings.HasPrefix(version, prefix+"."))
+		default:
+			return false, fmt.Errorf("unsupported requirement: %s", constraint)
+		}
+
+		if !satisfied {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// requestedFastlaneVersion returns the fastlane version the step is going to install,
+// from the fastlane_version input or the Gemfile.lock, empty if unknown.
+func (configs ConfigsModel) requestedFastlaneVersion() (string, error) {
+	if configs.FastlaneVersion != "" {
+		return configs.FastlaneVersion, nil
+	}
+	if configs.GemfilePath == "" {
+		return "", nil
+	}
+
+	gemfileLockPth := filepath.Join(filepath.Dir(configs.GemfilePath), "Gemfile.lock")
+	if exist, err := pathutil.IsPathExists(gemfileLockPth); err != nil || !exist {
+		return "", err
+	}
+	return gemVersionFromGemfileLock("fastlane", gemfileLockPth)
+}
+
+// checkRubyCompatibility fails if the active Ruby can't install the requested fastlane version.
+// The requirement is looked up on the gem source, the check is skipped if it's not available.
+func (configs ConfigsModel) checkRubyCompatibility() error {
+	fastlaneVersion, err := configs.requestedFastlaneVersion()
+	if err != nil {
+		return err
+	}
+	if fastlaneVersion == "" {
+		return nil
+	}
+
+	rubyVersion, err := activeRubyVersion()
+	if err != nil {
+		return err
+	}
+
+	resolvedVersion, requirement, err := fastlaneRubyRequirement(configs.GemSource, fastlaneVersion)
+	if err != nil {
+		log.Warnf("Failed to get the Ruby requirement of fastlane %s, skipping the compatibility check, error: %s", fastlaneVersion, err)
+		return nil
+	}
+	if requirement == "" {
+		return nil
+	}
+
+	satisfied, err := rubyRequirementSatisfied(rubyVersion, requirement)
+	if err != nil {
+		log.Warnf("Failed to check the Ruby requirement of fastlane %s, skipping the compatibility check, error: %s", resolvedVersion, err)
+		return nil
+	}
+	if !satisfied {
+		return fmt.Errorf("fastlane %s requires Ruby %s, but the active Ruby is %s, set ruby_version to a compatible Ruby or use an older fastlane version", resolvedVersion, requirement, rubyVersion)
+	}
+
+	log.Printf("Ruby %s satisfies the requirement of fastlane %s: %s", rubyVersion, resolvedVersion, requirement)
+	return nil
+}
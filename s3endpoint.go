@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// s3ConfigHook is loaded through RUBYOPT, match has no option for the S3 client endpoint.
+// The aws-sdk settings are applied once the sdk is loaded, before match creates its S3 client.
+const s3ConfigHook = `module Kernel
+  alias_method :match_step_original_require, :require
+
+  def require(path)
+    loaded = match_step_original_require(path)
+    if !$match_step_s3_configured && defined?(Aws) && Aws.respond_to?(:config)
+      $match_step_s3_configured = true
+      Aws.config.update(s3: %s)
+    end
+    loaded
+  end
+  private :require
+end
+`
+
+// s3ClientOptions renders the aws-sdk S3 client options as a ruby hash.
+func (configs ConfigsModel) s3ClientOptions() string {
+	options := []string{}
+	if configs.S3Endpoint != "" {
+		options = append(options, "endpoint: "+rubyString(configs.S3Endpoint))
+	}
+	if configs.S3ForcePathStyle == "yes" {
+		options = append(options, "force_path_style: true")
+	}
+	return "{ " + strings.Join(options, ", ") + " }"
+}
+
+// writeS3ConfigHook writes the ruby file configuring the S3 client of match, removed on exit,
+// and returns the RUBYOPT env to load it with.
+func (configs ConfigsModel) writeS3ConfigHook() (string, error) {
+	tmpDir, err := pathutil.NormalizedOSTempDirPath("match_s3")
+	if err != nil {
+		return "", err
+	}
+	registerCleanup(func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warnf("Failed to remove the S3 client configuration, error: %s", err)
+		}
+	})
+
+	pth := filepath.Join(tmpDir, "match_s3_config.rb")
+	if err := fileutil.WriteStringToFile(pth, fmt.Sprintf(s3ConfigHook, configs.s3ClientOptions())); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(os.Getenv("RUBYOPT") + " -r" + pth), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// writeSecretFile writes the content to a new temporary directory,
+// the file is readable only by the current user.
+func writeSecretFile(tmpDirPrefix, fileName string, content []byte) (string, error) {
+	tmpDir, err := pathutil.NormalizedOSTempDirPath(tmpDirPrefix)
+	if err != nil {
+		return "", err
+	}
+
+	pth := filepath.Join(tmpDir, fileName)
+	if err := fileutil.WriteBytesToFileWithPermission(pth, content, 0600); err != nil {
+		return "", err
+	}
+
+	return pth, nil
+}
+
+// registerSecretFileCleanup removes the secret file when the step exits.
+func registerSecretFileCleanup(pth, description string) {
+	registerCleanup(func() {
+		if err := secureRemoveFile(pth); err != nil {
+			log.Warnf("Failed to remove %s, error: %s", description, err)
+		}
+	})
+}
+
+// secureRemoveFile overwrites the file content with zeros before removing it.
+func secureRemoveFile(pth string) error {
+	info, err := os.Stat(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if err := fileutil.WriteBytesToFileWithPermission(pth, make([]byte, info.Size()), 0600); err != nil {
+		return err
+	}
+
+	return os.Remove(pth)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// appleServiceConnectionModel is the App Store Connect API key part of
+// the Apple service connection data, Bitrise provides for the build.
+type appleServiceConnectionModel struct {
+	KeyID      string `json:"key_id"`
+	IssuerID   string `json:"issuer_id"`
+	PrivateKey string `json:"private_key"`
+}
+
+// fetchAppleServiceConnection downloads the Apple service connection of the build,
+// the same way the official Bitrise steps do.
+func fetchAppleServiceConnection(buildURL, buildAPIToken string) (appleServiceConnectionModel, error) {
+	url := strings.TrimSuffix(buildURL, "/") + "/apple_developer_portal_data.json"
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return appleServiceConnectionModel{}, err
+	}
+	req.Header.Set("BUILD_API_TOKEN", buildAPIToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return appleServiceConnectionModel{}, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body, error: %s", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return appleServiceConnectionModel{}, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+	}
+
+	var connection appleServiceConnectionModel
+	if err := json.NewDecoder(resp.Body).Decode(&connection); err != nil {
+		return appleServiceConnectionModel{}, fmt.Errorf("failed to parse response, error: %s", err)
+	}
+
+	return connection, nil
+}
+
+// hasAppleCredentials reports whether any App Store Connect authentication is set in the inputs,
+// or an API key is set in the environment.
+func (configs ConfigsModel) hasAppleCredentials() bool {
+	return configs.APIKeyPath != "" || configs.APIKeyContentBase64 != "" || configs.APIKeyID != "" || configs.AppleID != "" || len(apiKeyEnvKeys()) > 0
+}
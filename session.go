@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// olympusSessionURL is the endpoint spaceship checks the App Store Connect session with.
+const olympusSessionURL = "https://appstoreconnect.apple.com/olympus/v1/session"
+
+// sessionAgeWarningDays is the age Apple sessions usually expire after.
+const sessionAgeWarningDays = 25
+
+// sessionCookieModel is a cookie of the FASTLANE_SESSION, serialized by spaceauth as HTTP::Cookie YAML objects.
+type sessionCookieModel struct {
+	Name      string
+	Value     string
+	CreatedAt time.Time
+}
+
+func yamlScalar(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseFastlaneSession reads the cookies of the session, the literal \n sequences
+// of a session copied from the spaceauth output are accepted too.
+func parseFastlaneSession(session string) []sessionCookieModel {
+	session = strings.Replace(session, `\n`, "\n", -1)
+
+	cookies := []sessionCookieModel{}
+	for _, line := range strings.Split(session, "\n") {
+		if strings.Contains(line, "!ruby/object:HTTP::Cookie") {
+			cookies = append(cookies, sessionCookieModel{})
+			continue
+		}
+		if len(cookies) == 0 {
+			continue
+		}
+
+		split := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+
+		cookie := &cookies[len(cookies)-1]
+		switch split[0] {
+		case "name":
+			cookie.Name = yamlScalar(split[1])
+		case "value":
+			cookie.Value = yamlScalar(split[1])
+		case "created_at":
+			for _, layout := range []string{"2006-01-02 15:04:05.999999999 -07:00", "2006-01-02 15:04:05 -07:00", time.RFC3339Nano} {
+				if createdAt, err := time.Parse(layout, yamlScalar(split[1])); err == nil {
+					cookie.CreatedAt = createdAt
+					break
+				}
+			}
+		}
+	}
+	return cookies
+}
+
+// sessionCreatedAt returns when the oldest cookie of the session was created, zero if unknown.
+func sessionCreatedAt(cookies []sessionCookieModel) time.Time {
+	createdAt := time.Time{}
+	for _, cookie := range cookies {
+		if !cookie.CreatedAt.IsZero() && (createdAt.IsZero() || cookie.CreatedAt.Before(createdAt)) {
+			createdAt = cookie.CreatedAt
+		}
+	}
+	return createdAt
+}
+
+// errInvalidSession is returned if the session can't be used to log in.
+type errInvalidSession struct {
+	reason string
+}
+
+func (e errInvalidSession) Error() string {
+	return e.reason + ", generate a new one with fastlane spaceauth"
+}
+
+// checkFastlaneSession calls the App Store Connect session endpoint with the session cookies,
+// and warns if the session is old. Only an errInvalidSession means the session is unusable.
+func checkFastlaneSession(session string) error {
+	cookies := parseFastlaneSession(session)
+	if len(cookies) == 0 {
+		return errInvalidSession{reason: "no cookie found in the session"}
+	}
+
+	if createdAt := sessionCreatedAt(cookies); !createdAt.IsZero() {
+		days := int(time.Since(createdAt).Hours() / 24)
+		if days >= sessionAgeWarningDays {
+			log.Warnf("The session was created %d days ago, Apple sessions usually expire after about a month", days)
+		} else {
+			log.Printf("The session was created %d days ago", days)
+		}
+	}
+
+	pairs := []string{}
+	for _, cookie := range cookies {
+		if cookie.Name != "" {
+			pairs = append(pairs, cookie.Name+"="+cookie.Value)
+		}
+	}
+
+	req, err := http.NewRequest("GET", olympusSessionURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Cookie", strings.Join(pairs, "; "))
+	req.Header.Set("Accept", "application/json")
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body, error: %s", err)
+		}
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errInvalidSession{reason: fmt.Sprintf("the session is expired or invalid (status code: %d)", resp.StatusCode)}
+	}
+	return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+}
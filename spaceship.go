@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// spaceshipOutputPattern selects the fastlane output lines related to the Apple login.
+var spaceshipOutputPattern = regexp.MustCompile(`(?i)spaceship|session|cookie|login|authenticat|2fa|two-(factor|step)|apple id|itunes connect|app store connect|401|403`)
+
+// spaceshipCookieKeyPattern selects the cookie attributes safe to share, leaving out the values.
+var spaceshipCookieKeyPattern = regexp.MustCompile(`^\s*(name|domain|path|expires|expires_at|created_at|accessed_at|max_age):`)
+
+// spaceshipCookieDiagnostics describes the cached spaceship sessions without their values.
+func spaceshipCookieDiagnostics() []string {
+	lines := []string{}
+
+	cookies, err := filepath.Glob(filepath.Join(pathutil.UserHomeDir(), ".fastlane", "spaceship", "*", "cookie"))
+	if err != nil || len(cookies) == 0 {
+		return append(lines, "No cached spaceship session found in ~/.fastlane/spaceship")
+	}
+
+	for _, pth := range cookies {
+		info, err := os.Stat(pth)
+		if err != nil {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s (modified: %s)", pth, info.ModTime().Format("2006-01-02 15:04:05 MST")))
+
+		content, err := fileutil.ReadStringFromFile(pth)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(content, "\n") {
+			if spaceshipCookieKeyPattern.MatchString(line) {
+				lines = append(lines, "  "+strings.TrimSpace(line))
+			}
+		}
+	}
+
+	return lines
+}
+
+// spaceshipOutputLines returns the login related lines of the fastlane output, with their neighbours.
+func spaceshipOutputLines(out string) []string {
+	outLines := strings.Split(out, "\n")
+	selected := map[int]bool{}
+	for i, line := range outLines {
+		if spaceshipOutputPattern.MatchString(line) {
+			for j := i - 2; j <= i+2; j++ {
+				if j >= 0 && j < len(outLines) {
+					selected[j] = true
+				}
+			}
+		}
+	}
+
+	lines := []string{}
+	for i, line := range outLines {
+		if selected[i] {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// writeSpaceshipDiagnostics writes the Apple login diagnostics into the deploy dir,
+// the session cookie values are never included.
+func writeSpaceshipDiagnostics(out string) {
+	deployDir := os.Getenv("BITRISE_DEPLOY_DIR")
+	if deployDir == "" {
+		return
+	}
+
+	lines := []string{"# Spaceship session diagnostics", ""}
+
+	lines = append(lines, "## Cached sessions", "")
+	lines = append(lines, spaceshipCookieDiagnostics()...)
+
+	lines = append(lines, "", "## fastlane output", "")
+	lines = append(lines, spaceshipOutputLines(out)...)
+
+	pth := filepath.Join(deployDir, "spaceship_diagnostics.txt")
+	if err := fileutil.WriteStringToFile(pth, strings.Join(lines, "\n")+"\n"); err != nil {
+		log.Warnf("Failed to write spaceship diagnostics, error: %s", err)
+		return
+	}
+
+	log.Printf("Spaceship session diagnostics written to: %s", pth)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+// isSSHGitURL reports whether the git url is cloned over SSH,
+// either as ssh://host/repo or in the scp-like user@host:repo form.
+func isSSHGitURL(url string) bool {
+	if strings.HasPrefix(url, "ssh://") {
+		return true
+	}
+	return regexp.MustCompile(`^[\w.-]+@[\w.-]+:`).MatchString(url)
+}
+
+// checkSSHAgent makes sure an ssh-agent with at least one identity is available,
+// like the one the Activate SSH key step starts.
+func checkSSHAgent() error {
+	if os.Getenv("SSH_AUTH_SOCK") == "" {
+		return errors.New("no ssh-agent is running (SSH_AUTH_SOCK is not set), add the Activate SSH key step before this step or provide git_private_key")
+	}
+
+	out, err := command.New("ssh-add", "-l").RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		if strings.Contains(out, "no identities") {
+			return errors.New("the ssh-agent has no identities, add the Activate SSH key step before this step or provide git_private_key")
+		}
+		return fmt.Errorf("failed to list the ssh-agent identities, output: %s, error: %s", out, err)
+	}
+
+	return nil
+}
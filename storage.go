@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-tools/go-steputils/input"
+)
+
+// validateStorage checks the inputs required by the selected storage mode.
+func (configs ConfigsModel) validateStorage() error {
+	if err := input.ValidateWithOptions(configs.StorageMode, "git", "s3", "google_cloud", "gitlab_secure_files"); err != nil {
+		return fmt.Errorf("Storage mode, %s", err)
+	}
+
+	switch configs.StorageMode {
+	case "git":
+		if err := input.ValidateIfNotEmpty(configs.GitURL); err != nil {
+			return fmt.Errorf("Git Url %s", err)
+		}
+
+		if strings.HasPrefix(configs.GitURL, "file://") {
+			if err := input.ValidateIfDirExists(strings.TrimPrefix(configs.GitURL, "file://")); err != nil {
+				return fmt.Errorf("Git Url %s", err)
+			}
+		}
+
+		if configs.GitDeployKey != "" {
+			if configs.GitPrivateKey != "" {
+				return errors.New("Git deploy key and Git private key can not be set at the same time")
+			}
+
+			if !strings.Contains(configs.GitDeployKey, "PRIVATE KEY") {
+				return errors.New("Git deploy key, not a private key")
+			}
+		}
+
+		if configs.GitRef != "" {
+			if configs.GitBranch != "" {
+				return errors.New("Git ref and Git branch can not be set at the same time")
+			}
+
+			if configs.Command != "fetch" || configs.Readonly != "yes" {
+				return errors.New("Git ref, only available for the fetch command in readonly mode")
+			}
+
+			if isCommitSHA(configs.GitRef) && configs.FetchEngine != "native" {
+				return errors.New("Git ref, match can only check out a tag, a commit requires the native fetch engine")
+			}
+		}
+
+		if configs.GitPrivateKey != "" && !strings.Contains(configs.GitPrivateKey, "PRIVATE KEY") {
+			if err := input.ValidateIfPathExists(configs.GitPrivateKey); err != nil {
+				return fmt.Errorf("Git private key %s", err)
+			}
+		}
+	case "s3":
+		if err := input.ValidateIfNotEmpty(configs.S3Bucket); err != nil {
+			return fmt.Errorf("S3 bucket %s", err)
+		}
+
+		if (configs.S3AccessKey == "") != (configs.S3SecretAccessKey == "") {
+			return errors.New("S3 access key and S3 secret access key must be provided together")
+		}
+
+		if configs.S3Endpoint != "" && !isRemoteURL(configs.S3Endpoint) {
+			return fmt.Errorf("S3 endpoint, not a http(s) url: %s", configs.S3Endpoint)
+		}
+
+		if err := input.ValidateWithOptions(configs.S3ForcePathStyle, "yes", "no"); err != nil {
+			return fmt.Errorf("S3 force path style, %s", err)
+		}
+	case "google_cloud":
+		if err := input.ValidateIfNotEmpty(configs.GoogleCloudBucketName); err != nil {
+			return fmt.Errorf("Google Cloud bucket name %s", err)
+		}
+
+		if configs.GoogleCloudKeysFile != "" && configs.GoogleCloudKeysContent != "" {
+			return errors.New("Google Cloud keys file and Google Cloud keys content can not be set at the same time")
+		}
+
+		if configs.GoogleCloudKeysFile != "" {
+			if err := input.ValidateIfPathExists(configs.GoogleCloudKeysFile); err != nil {
+				return fmt.Errorf("Google Cloud keys file %s", err)
+			}
+		}
+
+		if configs.GoogleCloudKeysContent != "" && !json.Valid([]byte(configs.GoogleCloudKeysContent)) {
+			return errors.New("Google Cloud keys content, not a valid JSON")
+		}
+	case "gitlab_secure_files":
+		if err := input.ValidateIfNotEmpty(configs.GitlabProject); err != nil {
+			return fmt.Errorf("GitLab project %s", err)
+		}
+
+		if configs.GitlabJobToken != "" && configs.GitlabPrivateToken != "" {
+			return errors.New("GitLab job token and GitLab private token can not be set at the same time")
+		}
+	}
+
+	// Only the git and s3 storages are encrypted by match
+	if configs.StorageMode == "git" || configs.StorageMode == "s3" {
+		if err := input.ValidateIfNotEmpty(configs.DecryptPassword); err != nil {
+			return fmt.Errorf("Decrypt Password %s", err)
+		}
+	}
+
+	return nil
+}
+
+// storageArgs returns the match arguments of the selected storage mode,
+// nothing if the storage is configured in the Matchfile.
+func (configs ConfigsModel) storageArgs(gitBasicAuthorization, googleCloudKeysFile string) []string {
+	if configs.usesMatchfileStorage() {
+		return nil
+	}
+
+	args := []string{"--storage_mode", configs.StorageMode}
+
+	switch configs.StorageMode {
+	case "git":
+		args = append(args, "--git_url", configs.GitURL)
+
+		if branch := configs.storageBranch(); branch != "" {
+			args = append(args, "--git_branch", branch)
+		}
+
+		if gitBasicAuthorization != "" {
+			args = append(args, "--git_basic_authorization", gitBasicAuthorization)
+		}
+
+		if configs.ShallowClone == "yes" {
+			args = append(args, "--shallow_clone")
+		}
+
+		if configs.cloneBranchDirectly() {
+			args = append(args, "--clone_branch_directly")
+		}
+
+		if configs.GitFullName != "" {
+			args = append(args, "--git_full_name", configs.GitFullName)
+		}
+
+		if configs.GitUserEmail != "" {
+			args = append(args, "--git_user_email", configs.GitUserEmail)
+		}
+	case "s3":
+		args = append(args, "--s3_bucket", configs.S3Bucket)
+
+		if configs.S3Region != "" {
+			args = append(args, "--s3_region", configs.S3Region)
+		}
+
+		if configs.S3ObjectPrefix != "" {
+			args = append(args, "--s3_object_prefix", configs.S3ObjectPrefix)
+		}
+
+		if configs.S3AccessKey != "" {
+			args = append(args, "--s3_access_key", configs.S3AccessKey)
+			args = append(args, "--s3_secret_access_key", configs.S3SecretAccessKey)
+		}
+	case "google_cloud":
+		args = append(args, "--google_cloud_bucket_name", configs.GoogleCloudBucketName)
+
+		if googleCloudKeysFile != "" {
+			args = append(args, "--google_cloud_keys_file", googleCloudKeysFile)
+		}
+
+		if configs.GoogleCloudProjectID != "" {
+			args = append(args, "--google_cloud_project_id", configs.GoogleCloudProjectID)
+		}
+	case "gitlab_secure_files":
+		args = append(args, "--gitlab_project", configs.GitlabProject)
+
+		if configs.GitlabHost != "" {
+			args = append(args, "--gitlab_host", configs.GitlabHost)
+		}
+
+		if configs.GitlabJobToken != "" {
+			args = append(args, "--job_token", configs.GitlabJobToken)
+		}
+
+		if configs.GitlabPrivateToken != "" {
+			args = append(args, "--private_token", configs.GitlabPrivateToken)
+		}
+	}
+
+	return args
+}
+
+// validateChangePassword checks the inputs required to re-encrypt the storage with a new password.
+func (configs ConfigsModel) validateChangePassword() error {
+	if configs.StorageMode != "git" && configs.StorageMode != "s3" {
+		return fmt.Errorf("Change password, not available for storage mode: %s", configs.StorageMode)
+	}
+
+	if err := input.ValidateIfNotEmpty(configs.NewDecryptPassword); err != nil {
+		return fmt.Errorf("New decrypt password %s", err)
+	}
+
+	if configs.NewDecryptPassword == configs.DecryptPassword {
+		return errors.New("New decrypt password must be different from Decrypt password")
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"time"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+)
+
+type summaryProfileModel struct {
+	Name           string    `json:"name"`
+	UUID           string    `json:"uuid"`
+	Type           string    `json:"type"`
+	AppID          string    `json:"app_identifier"`
+	TeamID         string    `json:"team_id"`
+	ExpirationDate time.Time `json:"expiration_date"`
+}
+
+type summaryCertificateModel struct {
+	CommonName     string    `json:"common_name"`
+	Serial         string    `json:"serial"`
+	SHA1           string    `json:"sha1"`
+	ExpirationDate time.Time `json:"expiration_date"`
+}
+
+// summaryModel is the machine-readable summary of a fetch run,
+// durations are in seconds.
+type summaryModel struct {
+	FastlaneVersion string                    `json:"fastlane_version"`
+	Types           []string                  `json:"types"`
+	AppIdentifiers  []string                  `json:"app_identifiers"`
+	Profiles        []summaryProfileModel     `json:"profiles"`
+	Certificates    []summaryCertificateModel `json:"certificates"`
+	Durations       map[string]float64        `json:"durations"`
+}
+
+func newSummaryModel(fastlaneVersion string, types, appIDs []string, profiles []ProfileModel, certificates []CertificateModel) summaryModel {
+	summary := summaryModel{
+		FastlaneVersion: fastlaneVersion,
+		Types:           types,
+		AppIdentifiers:  appIDs,
+		Profiles:        []summaryProfileModel{},
+		Certificates:    []summaryCertificateModel{},
+		Durations:       map[string]float64{},
+	}
+
+	for _, profile := range profiles {
+		summary.Profiles = append(summary.Profiles, summaryProfileModel{
+			Name:           profile.Name,
+			UUID:           profile.UUID,
+			Type:           profile.Type,
+			AppID:          profile.AppID,
+			TeamID:         profile.TeamID,
+			ExpirationDate: profile.ExpirationDate,
+		})
+	}
+
+	for _, certificate := range certificates {
+		summary.Certificates = append(summary.Certificates, summaryCertificateModel{
+			CommonName:     certificate.CommonName,
+			Serial:         certificate.Serial,
+			SHA1:           certificate.SHA1,
+			ExpirationDate: certificate.ExpirationDate,
+		})
+	}
+
+	return summary
+}
+
+func writeSummary(pth string, summary summaryModel) error {
+	content, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteBytesToFile(pth, content)
+}
+
+// fastlaneVersionFromOutput returns the version printed by `fastlane -v`.
+func fastlaneVersionFromOutput(out string) string {
+	exp := regexp.MustCompile(`fastlane (\d+\.\d+\.\d+)`)
+	match := exp.FindStringSubmatch(out)
+	if len(match) == 2 {
+		return match[1]
+	}
+	return ""
+}
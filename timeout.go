@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// commandTimeout limits the duration of a single fastlane run, zero means no limit.
+var commandTimeout time.Duration
+
+// timeoutError is returned if the command is killed because of the timeout.
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.timeout)
+}
+
+// runWithTimeout runs the command in its own process group and kills the whole group,
+// after logging its processes, if it does not finish within the timeout.
+func runWithTimeout(cmd *exec.Cmd, timeout time.Duration) error {
+	if timeout <= 0 {
+		return cmd.Run()
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		pgid := cmd.Process.Pid
+
+		fmt.Println()
+		log.Errorf("Command timed out after %s, processes still running:", timeout)
+		logProcessGroup(pgid)
+
+		if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
+			log.Warnf("Failed to kill the process group, error: %s", err)
+		}
+		<-done
+
+		return timeoutError{timeout: timeout}
+	}
+}
+
+// logProcessGroup prints the processes of the group, to see what the command was waiting for.
+func logProcessGroup(pgid int) {
+	out, err := command.New("ps", "-A", "-o", "pid=,pgid=,etime=,command=").RunAndReturnTrimmedOutput()
+	if err != nil {
+		log.Warnf("Failed to list processes, error: %s", err)
+		return
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[1] == strconv.Itoa(pgid) {
+			log.Printf("%s", line)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-tools/go-steputils/input"
+)
+
+// matchGroup is a single match run: one type with the app identifiers it covers.
+// Platform, Readonly and Options override the step inputs when set.
+type matchGroup struct {
+	Type     string
+	AppIDs   []string
+	Platform string
+	Readonly *bool
+	Options  []string
+}
+
+// parseAppIdentifierTypes parses `identifier:type` entries and groups the
+// identifiers by type, keeping the order the types first appear in.
+func parseAppIdentifierTypes(list string) ([]matchGroup, error) {
+	groups := []matchGroup{}
+	indexByType := map[string]int{}
+
+	for _, entry := range splitList(list) {
+		split := strings.Split(entry, ":")
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid entry: %s, expected format: identifier:type", entry)
+		}
+
+		appID := strings.TrimSpace(split[0])
+		matchType := strings.TrimSpace(split[1])
+
+		if appID == "" {
+			return nil, fmt.Errorf("invalid entry: %s, missing identifier", entry)
+		}
+
+		if err := input.ValidateWithOptions(matchType, "adhoc", "appstore", "development", "enterprise"); err != nil {
+			return nil, fmt.Errorf("invalid entry: %s, %s", entry, err)
+		}
+
+		idx, ok := indexByType[matchType]
+		if !ok {
+			idx = len(groups)
+			indexByType[matchType] = idx
+			groups = append(groups, matchGroup{Type: matchType})
+		}
+		groups[idx].AppIDs = append(groups[idx].AppIDs, appID)
+	}
+
+	return groups, nil
+}
+
+// matchGroups returns the match runs to perform, either from the matrix configuration,
+// the identifier to type mapping or every type with all of the app identifiers.
+func (configs ConfigsModel) matchGroups() ([]matchGroup, error) {
+	if configs.MatchMatrix != "" {
+		return parseMatchMatrix(configs.MatchMatrix)
+	}
+
+	if configs.AppIdentifierTypes != "" {
+		return parseAppIdentifierTypes(configs.AppIdentifierTypes)
+	}
+
+	groups := []matchGroup{}
+	for _, matchType := range splitList(configs.Type) {
+		groups = append(groups, matchGroup{Type: matchType, AppIDs: splitList(configs.AppID)})
+	}
+	return groups, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// removeInstalledAssets removes every copy of the provisioning profiles an earlier fetch installed
+// and the code signing identities it newly imported, based on the outputs that run exported.
+func removeInstalledAssets(keychain string) error {
+	profilePaths := strings.Split(os.Getenv("MATCH_INSTALLED_PROFILE_PATHS"), outputListSeparator)
+	sha1s := strings.Split(os.Getenv("MATCH_IMPORTED_IDENTITY_SHA1S"), outputListSeparator)
+
+	for _, pth := range profilePaths {
+		if pth == "" {
+			continue
+		}
+
+		if exist, err := pathutil.IsPathExists(pth); err != nil {
+			return err
+		} else if !exist {
+			log.Warnf("Provisioning profile not found: %s", pth)
+			continue
+		}
+
+		log.Printf("Removing provisioning profile: %s", pth)
+
+		if err := os.Remove(pth); err != nil {
+			return err
+		}
+	}
+
+	for _, sha1 := range sha1s {
+		if sha1 == "" {
+			continue
+		}
+
+		log.Printf("Removing code signing identity: %s", sha1)
+
+		args := []string{"delete-identity", "-Z", sha1}
+		if keychain != "" {
+			args = append(args, keychain)
+		}
+
+		if _, err := runSecurity(args...); err != nil {
+			log.Warnf("Failed to remove code signing identity: %s, error: %s", sha1, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// isPullRequestBuild reports whether the build was triggered by a pull request.
+func isPullRequestBuild() bool {
+	return os.Getenv("BITRISE_PULL_REQUEST") != "" || os.Getenv("PR") == "true"
+}
+
+// writableGroups returns the groups match runs for with readonly disabled.
+func writableGroups(groups []matchGroup, readonly bool) []matchGroup {
+	writable := []matchGroup{}
+	for _, group := range groups {
+		groupReadonly := readonly
+		if group.Readonly != nil {
+			groupReadonly = *group.Readonly
+		}
+		if !groupReadonly {
+			writable = append(writable, group)
+		}
+	}
+	return writable
+}
+
+// printWriteModeBanner lists the assets match may create or modify on the Apple Developer Portal.
+func (configs ConfigsModel) printWriteModeBanner(groups []matchGroup, platform string) {
+	team := configs.TeamID
+	if team == "" {
+		team = configs.TeamName
+	}
+	if team == "" {
+		team = "the default team"
+	}
+
+	banner := strings.Repeat("=", 80)
+
+	fmt.Println()
+	log.Warnf("%s", banner)
+	log.Warnf("Readonly is disabled, match may create or modify on the Apple Developer Portal (%s):", team)
+	for _, group := range groups {
+		groupPlatform := platform
+		if group.Platform != "" {
+			groupPlatform = group.Platform
+		}
+		if groupPlatform == "" {
+			groupPlatform = "ios"
+		}
+
+		log.Warnf("- a %s certificate, if none is stored yet", matchCertificateType(group.Type))
+		for _, appID := range group.AppIDs {
+			log.Warnf("- the %s %s provisioning profile of %s", groupPlatform, group.Type, appID)
+		}
+	}
+	if configs.Devices != "" || configs.DevicesFile != "" {
+		log.Warnf("- the devices of the devices inputs, registered before match runs")
+	}
+	if configs.Force == "yes" {
+		log.Warnf("Force is enabled, the provisioning profiles are renewed on every run")
+	} else if configs.ForceForNewDevices == "yes" {
+		log.Warnf("Force for new devices is enabled, the provisioning profiles are renewed if devices were added")
+	}
+	log.Warnf("The created assets are committed to the storage")
+	log.Warnf("%s", banner)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+)
+
+// xcconfigIdentifier converts the app identifier the same way
+// the :c99extidentifier build setting operator does.
+func xcconfigIdentifier(appID string) string {
+	return regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(appID, "_")
+}
+
+// certificateForProfile returns the fetched certificate included in the profile.
+func certificateForProfile(profile ProfileModel, certificates []CertificateModel) (CertificateModel, bool) {
+	for _, sha1 := range profile.CertificateSHA1s {
+		for _, certificate := range certificates {
+			if certificate.SHA1 == sha1 {
+				return certificate, true
+			}
+		}
+	}
+	return CertificateModel{}, false
+}
+
+// xcconfigContent renders the manual code signing settings of the given type,
+// the profile and identity are selected by the target's PRODUCT_BUNDLE_IDENTIFIER.
+func xcconfigContent(matchType, teamID string, profiles []ProfileModel, certificates []CertificateModel) string {
+	profilesByAppID := map[string]ProfileModel{}
+	appIDs := []string{}
+	for _, profile := range profiles {
+		if profile.Type != matchType {
+			continue
+		}
+		if _, ok := profilesByAppID[profile.AppID]; !ok {
+			appIDs = append(appIDs, profile.AppID)
+		}
+		profilesByAppID[profile.AppID] = profile
+		if teamID == "" {
+			teamID = profile.TeamID
+		}
+	}
+	sort.Strings(appIDs)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Generated by the fastlane match step, type: %s\n\n", matchType)
+	fmt.Fprintf(&buf, "CODE_SIGN_STYLE = Manual\n")
+	fmt.Fprintf(&buf, "DEVELOPMENT_TEAM = %s\n", teamID)
+	fmt.Fprintf(&buf, "CODE_SIGN_IDENTITY = $(MATCH_CODE_SIGN_IDENTITY_$(PRODUCT_BUNDLE_IDENTIFIER:c99extidentifier))\n")
+	fmt.Fprintf(&buf, "PROVISIONING_PROFILE_SPECIFIER = $(MATCH_PROFILE_SPECIFIER_$(PRODUCT_BUNDLE_IDENTIFIER:c99extidentifier))\n")
+
+	for _, appID := range appIDs {
+		profile := profilesByAppID[appID]
+		identifier := xcconfigIdentifier(appID)
+
+		fmt.Fprintf(&buf, "\n// %s\n", appID)
+		if certificate, ok := certificateForProfile(profile, certificates); ok {
+			fmt.Fprintf(&buf, "MATCH_CODE_SIGN_IDENTITY_%s = %s\n", identifier, certificate.CommonName)
+		}
+		fmt.Fprintf(&buf, "MATCH_PROFILE_SPECIFIER_%s = %s\n", identifier, profile.Name)
+	}
+
+	return buf.String()
+}
+
+// writeXcconfigs writes the xcconfig of every type into the directory and returns their paths.
+func writeXcconfigs(dir string, types []string, teamID string, profiles []ProfileModel, certificates []CertificateModel) ([]string, error) {
+	pths := []string{}
+	for _, matchType := range types {
+		pth := filepath.Join(dir, fmt.Sprintf("match_%s.xcconfig", matchType))
+		if err := fileutil.WriteStringToFile(pth, xcconfigContent(matchType, teamID, profiles, certificates)); err != nil {
+			return nil, err
+		}
+		pths = append(pths, pth)
+	}
+	return pths, nil
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcodeproj"
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcscheme"
+	"github.com/bitrise-io/go-xcode/xcodeproject/xcworkspace"
+)
+
+// xcodeTarget is a native target of an Xcode project,
+// with its build settings by configuration name.
+type xcodeTarget struct {
+	Name          string
+	ProjectDir    string
+	ProductType   string
+	Dependencies  []string
+	BuildSettings map[string]map[string]interface{}
+}
+
+// findXcodeProjects returns the project itself, or the projects referenced by the workspace.
+func findXcodeProjects(projectPath string) ([]string, error) {
+	if !xcworkspace.IsWorkspace(projectPath) {
+		return []string{projectPath}, nil
+	}
+
+	workspace, err := xcworkspace.Open(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	locations, err := workspace.ProjectFileLocations()
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []string{}
+	for _, location := range locations {
+		if filepath.Base(location) != "Pods.xcodeproj" {
+			projects = append(projects, location)
+		}
+	}
+	return projects, nil
+}
+
+func configurationBuildSettings(configurationList xcodeproj.ConfigurationList) map[string]map[string]interface{} {
+	buildSettings := map[string]map[string]interface{}{}
+	for _, configuration := range configurationList.BuildConfigurations {
+		buildSettings[configuration.Name] = configuration.BuildSettings
+	}
+	return buildSettings
+}
+
+// readXcodeTargets parses the native targets of the project,
+// the project level build settings are merged into the target level ones.
+func readXcodeTargets(xcodeprojPath string) ([]xcodeTarget, error) {
+	project, err := xcodeproj.Open(xcodeprojPath)
+	if err != nil {
+		return nil, err
+	}
+
+	projectBuildSettings := configurationBuildSettings(project.Proj.BuildConfigurationList)
+
+	targets := []xcodeTarget{}
+	for _, target := range project.Proj.Targets {
+		if target.Type != xcodeproj.NativeTargetType {
+			continue
+		}
+
+		dependencies := []string{}
+		for _, dependency := range target.Dependencies {
+			if dependencyTarget, ok := project.Proj.Target(dependency.TargetID); ok {
+				dependencies = append(dependencies, dependencyTarget.Name)
+			}
+		}
+
+		buildSettings := configurationBuildSettings(target.BuildConfigurationList)
+		for configuration, settings := range buildSettings {
+			for key, value := range projectBuildSettings[configuration] {
+				if _, ok := settings[key]; !ok {
+					settings[key] = value
+				}
+			}
+		}
+
+		targets = append(targets, xcodeTarget{
+			Name:          target.Name,
+			ProjectDir:    filepath.Dir(xcodeprojPath),
+			ProductType:   target.ProductType,
+			Dependencies:  dependencies,
+			BuildSettings: buildSettings,
+		})
+	}
+	return targets, nil
+}
+
+// readScheme returns the archived target names and the archive configuration of the scheme.
+func readScheme(projectPath, scheme string) ([]string, string, error) {
+	var model *xcscheme.Scheme
+	if xcworkspace.IsWorkspace(projectPath) {
+		workspace, err := xcworkspace.Open(projectPath)
+		if err != nil {
+			return nil, "", err
+		}
+		if model, _, err = workspace.Scheme(scheme); err != nil {
+			return nil, "", err
+		}
+	} else {
+		project, err := xcodeproj.Open(projectPath)
+		if err != nil {
+			return nil, "", err
+		}
+		if model, _, err = project.Scheme(scheme); err != nil {
+			return nil, "", err
+		}
+	}
+
+	targetNames := []string{}
+	for _, entry := range model.BuildAction.BuildActionEntries {
+		if entry.BuildForArchiving == "YES" {
+			targetNames = append(targetNames, entry.BuildableReference.BlueprintName)
+		}
+	}
+	return targetNames, model.ArchiveAction.BuildConfiguration, nil
+}
+
+// isSignableTarget reports whether the target needs a provisioning profile.
+func isSignableTarget(target xcodeTarget) bool {
+	if strings.Contains(target.ProductType, "test") {
+		return false
+	}
+	return strings.Contains(target.ProductType, "application") ||
+		strings.Contains(target.ProductType, "extension") ||
+		strings.Contains(target.ProductType, "watchapp")
+}
+
+// targetBuildSetting returns the resolved build setting of the configuration,
+// using the Release configuration if the given one does not exist.
+func targetBuildSetting(target xcodeTarget, configuration, key string) string {
+	settings, ok := target.BuildSettings[configuration]
+	if !ok {
+		settings, ok = target.BuildSettings["Release"]
+	}
+	if !ok {
+		names := []string{}
+		for name := range target.BuildSettings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) > 0 {
+			settings = target.BuildSettings[names[0]]
+		}
+	}
+
+	return resolveBuildSetting(target, settings, key, 0)
+}
+
+var (
+	buildSettingReferenceExp = regexp.MustCompile(`\$[({]([A-Za-z0-9_]+)(:[A-Za-z0-9_]+)?[)}]`)
+	rfc1034InvalidCharExp    = regexp.MustCompile(`[^A-Za-z0-9-.]`)
+)
+
+func resolveBuildSetting(target xcodeTarget, settings map[string]interface{}, key string, depth int) string {
+	value, _ := settings[key].(string)
+	if value == "" {
+		switch key {
+		case "TARGET_NAME":
+			value = target.Name
+		case "PRODUCT_NAME":
+			value = "$(TARGET_NAME)"
+		case "SRCROOT", "PROJECT_DIR":
+			value = target.ProjectDir
+		}
+	}
+
+	if depth > 10 {
+		return value
+	}
+
+	return buildSettingReferenceExp.ReplaceAllStringFunc(value, func(reference string) string {
+		match := buildSettingReferenceExp.FindStringSubmatch(reference)
+		resolved := resolveBuildSetting(target, settings, match[1], depth+1)
+		if resolved == "" {
+			return reference
+		}
+		if match[2] == ":rfc1034identifier" {
+			resolved = rfc1034InvalidCharExp.ReplaceAllString(resolved, "-")
+		}
+		return resolved
+	})
+}
+
+// selectTargets returns the signable targets of the scheme including their dependencies,
+// or every signable target if no scheme is given.
+func selectTargets(projectPath, scheme string) ([]xcodeTarget, string, error) {
+	projects, err := findXcodeProjects(projectPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	targetsByName := map[string]xcodeTarget{}
+	targetNames := []string{}
+	for _, project := range projects {
+		targets, err := readXcodeTargets(project)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, target := range targets {
+			targetsByName[target.Name] = target
+			targetNames = append(targetNames, target.Name)
+		}
+	}
+
+	configuration := ""
+	if scheme != "" {
+		targetNames, configuration, err = readScheme(projectPath, scheme)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	selected := []xcodeTarget{}
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		target, ok := targetsByName[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+
+		if isSignableTarget(target) {
+			selected = append(selected, target)
+		}
+		for _, dependency := range target.Dependencies {
+			visit(dependency)
+		}
+	}
+	for _, name := range targetNames {
+		visit(name)
+	}
+
+	return selected, configuration, nil
+}
+
+// detectAppIDs collects the bundle identifiers of the project's signable targets.
+func detectAppIDs(projectPath, scheme string) ([]string, error) {
+	targets, configuration, err := selectTargets(projectPath, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	appIDs := []string{}
+	seen := map[string]bool{}
+	for _, target := range targets {
+		appID := targetBuildSetting(target, configuration, "PRODUCT_BUNDLE_IDENTIFIER")
+		if appID == "" || strings.Contains(appID, "$") {
+			continue
+		}
+		if !seen[appID] {
+			seen[appID] = true
+			appIDs = append(appIDs, appID)
+		}
+	}
+
+	if len(appIDs) == 0 {
+		return nil, errors.New("no bundle identifier found")
+	}
+	return appIDs, nil
+}
+
+// detectTeamID returns the DEVELOPMENT_TEAM build setting of the project's signable targets.
+func detectTeamID(projectPath, scheme string) (string, error) {
+	targets, configuration, err := selectTargets(projectPath, scheme)
+	if err != nil {
+		return "", err
+	}
+
+	for _, target := range targets {
+		teamID := targetBuildSetting(target, configuration, "DEVELOPMENT_TEAM")
+		if teamID != "" && !strings.Contains(teamID, "$") {
+			return teamID, nil
+		}
+	}
+
+	return "", errors.New("no DEVELOPMENT_TEAM build setting found")
+}
+
+var sdkPlatforms = map[string]string{
+	"iphoneos":  "ios",
+	"macosx":    "macos",
+	"appletvos": "tvos",
+}
+
+// detectPlatform returns the match platform of the project's first signable target, based on its SDKROOT.
+// An iOS target with SUPPORTS_MACCATALYST enabled is detected as catalyst if the Mac Catalyst variant is requested.
+func detectPlatform(projectPath, scheme string, catalyst bool) (string, error) {
+	targets, configuration, err := selectTargets(projectPath, scheme)
+	if err != nil {
+		return "", err
+	}
+
+	if len(targets) == 0 {
+		return "", errors.New("no app or extension target found")
+	}
+
+	sdk := targetBuildSetting(targets[0], configuration, "SDKROOT")
+	platform, ok := sdkPlatforms[sdk]
+	if !ok {
+		return "", fmt.Errorf("unsupported SDKROOT: %s", sdk)
+	}
+
+	if platform == "ios" && catalyst && targetBuildSetting(targets[0], configuration, "SUPPORTS_MACCATALYST") == "YES" {
+		return "catalyst", nil
+	}
+	return platform, nil
+}
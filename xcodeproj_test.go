@@ -0,0 +1,146 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The fixture is an Xcode 15 iOS app project with a widget extension and a unit test target,
+// the app bundle identifier is a project level user-defined setting and the app supports Mac Catalyst.
+var (
+	testProjectPath   = filepath.Join("testdata", "Sample", "Sample.xcodeproj")
+	testWorkspacePath = filepath.Join("testdata", "Sample", "Sample.xcworkspace")
+)
+
+func TestFindXcodeProjects(t *testing.T) {
+	projects, err := findXcodeProjects(testWorkspacePath)
+	if err != nil {
+		t.Fatalf("findXcodeProjects() error = %s", err)
+	}
+	absProjectPath, err := filepath.Abs(testProjectPath)
+	if err != nil {
+		t.Fatalf("filepath.Abs() error = %s", err)
+	}
+	if want := []string{absProjectPath}; !reflect.DeepEqual(projects, want) {
+		t.Errorf("findXcodeProjects() = %v, want %v", projects, want)
+	}
+}
+
+func TestReadXcodeTargets(t *testing.T) {
+	targets, err := readXcodeTargets(testProjectPath)
+	if err != nil {
+		t.Fatalf("readXcodeTargets() error = %s", err)
+	}
+
+	names := []string{}
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+	if want := []string{"Sample", "SampleTests", "SampleWidgetExtension"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("readXcodeTargets() names = %v, want %v", names, want)
+	}
+
+	app := targets[0]
+	if app.ProductType != "com.apple.product-type.application" {
+		t.Errorf("ProductType = %s", app.ProductType)
+	}
+	if want := []string{"SampleWidgetExtension"}; !reflect.DeepEqual(app.Dependencies, want) {
+		t.Errorf("Dependencies = %v, want %v", app.Dependencies, want)
+	}
+	if got := app.BuildSettings["Release"]["DEVELOPMENT_TEAM"]; got != "9Z8Y7X6W5V" {
+		t.Errorf("project level DEVELOPMENT_TEAM = %v", got)
+	}
+	if got := app.BuildSettings["Debug"]["LD_RUNPATH_SEARCH_PATHS"]; !reflect.DeepEqual(got, []interface{}{"$(inherited)", "@executable_path/Frameworks"}) {
+		t.Errorf("LD_RUNPATH_SEARCH_PATHS = %v", got)
+	}
+}
+
+func TestTargetBuildSetting(t *testing.T) {
+	targets, err := readXcodeTargets(testProjectPath)
+	if err != nil {
+		t.Fatalf("readXcodeTargets() error = %s", err)
+	}
+
+	tests := []struct {
+		target        int
+		configuration string
+		key           string
+		want          string
+	}{
+		{target: 0, configuration: "Release", key: "PRODUCT_BUNDLE_IDENTIFIER", want: "io.platan.sample"},
+		{target: 0, configuration: "Debug", key: "PRODUCT_BUNDLE_IDENTIFIER", want: "io.platan.sample.debug"},
+		{target: 0, configuration: "Staging", key: "PRODUCT_BUNDLE_IDENTIFIER", want: "io.platan.sample"},
+		{target: 0, configuration: "Release", key: "PRODUCT_NAME", want: "Sample"},
+		{target: 0, configuration: "Release", key: "CODE_SIGN_ENTITLEMENTS", want: "Sample/Sample.entitlements"},
+		{target: 1, configuration: "Release", key: "PRODUCT_BUNDLE_IDENTIFIER", want: "io.platan.SampleTests"},
+		{target: 2, configuration: "Release", key: "PRODUCT_BUNDLE_IDENTIFIER", want: "io.platan.sample.widget"},
+		{target: 2, configuration: "Release", key: "SRCROOT", want: filepath.Join("testdata", "Sample")},
+	}
+
+	for _, tt := range tests {
+		if got := targetBuildSetting(targets[tt.target], tt.configuration, tt.key); got != tt.want {
+			t.Errorf("targetBuildSetting(%s, %s, %s) = %s, want %s", targets[tt.target].Name, tt.configuration, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSelectTargets(t *testing.T) {
+	tests := []struct {
+		name              string
+		projectPath       string
+		scheme            string
+		wantTargets       []string
+		wantConfiguration string
+	}{
+		{name: "project", projectPath: testProjectPath, wantTargets: []string{"Sample", "SampleWidgetExtension"}},
+		{name: "scheme", projectPath: testProjectPath, scheme: "Sample", wantTargets: []string{"Sample", "SampleWidgetExtension"}, wantConfiguration: "Release"},
+		{name: "workspace scheme", projectPath: testWorkspacePath, scheme: "Sample", wantTargets: []string{"Sample", "SampleWidgetExtension"}, wantConfiguration: "Release"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, configuration, err := selectTargets(tt.projectPath, tt.scheme)
+			if err != nil {
+				t.Fatalf("selectTargets() error = %s", err)
+			}
+
+			names := []string{}
+			for _, target := range targets {
+				names = append(names, target.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantTargets) {
+				t.Errorf("selectTargets() targets = %v, want %v", names, tt.wantTargets)
+			}
+			if configuration != tt.wantConfiguration {
+				t.Errorf("selectTargets() configuration = %s, want %s", configuration, tt.wantConfiguration)
+			}
+		})
+	}
+
+	if _, _, err := selectTargets(testProjectPath, "Missing"); err == nil {
+		t.Errorf("selectTargets() with a missing scheme error = nil, want an error")
+	}
+}
+
+func TestDetectFromProject(t *testing.T) {
+	appIDs, err := detectAppIDs(testWorkspacePath, "Sample")
+	if err != nil {
+		t.Fatalf("detectAppIDs() error = %s", err)
+	}
+	if want := []string{"io.platan.sample", "io.platan.sample.widget"}; !reflect.DeepEqual(appIDs, want) {
+		t.Errorf("detectAppIDs() = %v, want %v", appIDs, want)
+	}
+
+	if teamID, err := detectTeamID(testProjectPath, "Sample"); err != nil || teamID != "9Z8Y7X6W5V" {
+		t.Errorf("detectTeamID() = %s, %v, want 9Z8Y7X6W5V", teamID, err)
+	}
+
+	if platform, err := detectPlatform(testProjectPath, "Sample", false); err != nil || platform != "ios" {
+		t.Errorf("detectPlatform() = %s, %v, want ios", platform, err)
+	}
+
+	if platform, err := detectPlatform(testProjectPath, "Sample", true); err != nil || platform != "catalyst" {
+		t.Errorf("detectPlatform() with catalyst = %s, %v, want catalyst", platform, err)
+	}
+}